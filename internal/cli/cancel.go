@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"queuectl/internal/daemon"
+	"queuectl/internal/job"
+)
+
+var cancelCmd = &cobra.Command{
+	Use:   "cancel [job-id]",
+	Short: "Cancel a job",
+	Long: `Cancel a pending, scheduled, blocked or failed job, or stop a running one.
+A running job is stopped by the worker that owns it, even in another process: its
+process group is killed and the attempt is recorded as cancelled instead of retried.
+Jobs depending on a cancelled job are handled according to their dependency_policy.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jobID := args[0]
+
+		previous, err := job.Cancel(jobID)
+		if err != nil {
+			if errors.Is(err, job.ErrNotCancellable) {
+				return fmt.Errorf("❌ Job '%s' has already finished (state: %s)\n\n💡 Only pending, blocked, failed and processing jobs can be cancelled", jobID, previous)
+			}
+			return fmt.Errorf("❌ Failed to cancel job: %w\n\n💡 Check existing jobs: queuectl list", err)
+		}
+
+		if previous == job.StateProcessing {
+			// The worker daemon can stop the job right away; other workers pick up the request on their own
+			if _, err := daemon.Send(daemon.Request{Command: daemon.CommandCancel, JobID: jobID}); err == nil {
+				fmt.Printf("✅ Running job '%s' is being stopped\n", jobID)
+				return nil
+			}
+			fmt.Printf("✅ Cancellation requested for running job '%s'\n", jobID)
+			fmt.Println("ℹ️  Its worker will stop the command within a few seconds")
+			return nil
+		}
+
+		fmt.Printf("✅ Job '%s' cancelled (was %s)\n", jobID, previous)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cancelCmd)
+}
+
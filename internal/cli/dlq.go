@@ -1,66 +1,316 @@
-package cli
-
-import (
-	"encoding/json"
-	"fmt"
-	"os"
-
-	"github.com/spf13/cobra"
-	"queuectl/internal/job"
-)
-
-var dlqListCmd = &cobra.Command{
-	Use:   "list",
-	Short: "List all jobs in the Dead Letter Queue",
-	Long:  `Display all jobs that have been moved to the Dead Letter Queue (permanently failed).`,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		jobs, err := job.ListByState(job.StateDead)
-		if err != nil {
-			return fmt.Errorf("failed to list DLQ jobs: %w", err)
-		}
-
-		if len(jobs) == 0 {
-			fmt.Println("ℹ️  No jobs in Dead Letter Queue")
-			return nil
-		}
-
-		// Output as JSON
-		encoder := json.NewEncoder(os.Stdout)
-		encoder.SetIndent("", "  ")
-		if err := encoder.Encode(jobs); err != nil {
-			return fmt.Errorf("failed to encode jobs: %w", err)
-		}
-
-		return nil
-	},
-}
-
-var dlqRetryCmd = &cobra.Command{
-	Use:   "retry [job-id]",
-	Short: "Retry a job from the Dead Letter Queue",
-	Long:  `Move a job from the Dead Letter Queue back to pending state for retry.`,
-	Args:  cobra.ExactArgs(1),
-	RunE: func(cmd *cobra.Command, args []string) error {
-		jobID := args[0]
-
-		if err := job.RetryDeadJob(jobID); err != nil {
-			return fmt.Errorf("❌ Failed to retry job: %w\n\n💡 Make sure the job ID exists in DLQ: queuectl dlq list", err)
-		}
-
-		fmt.Printf("✅ Job '%s' moved back to pending state\n", jobID)
-		return nil
-	},
-}
-
-var dlqCmd = &cobra.Command{
-	Use:   "dlq",
-	Short: "Manage Dead Letter Queue",
-	Long:  `Commands for managing the Dead Letter Queue (permanently failed jobs).`,
-}
-
-func init() {
-	dlqCmd.AddCommand(dlqListCmd)
-	dlqCmd.AddCommand(dlqRetryCmd)
-	rootCmd.AddCommand(dlqCmd)
-}
-
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"queuectl/internal/daemon"
+	"queuectl/internal/job"
+)
+
+var dlqListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all jobs in the Dead Letter Queue",
+	Long: `Display all jobs that have been moved to the Dead Letter Queue (permanently failed),
+most recently dead-lettered first. Use --filter to narrow the list down.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter, err := dlqFilter(cmd)
+		if err != nil {
+			return err
+		}
+
+		jobs, err := job.ListDead(filter)
+		if err != nil {
+			return fmt.Errorf("failed to list DLQ jobs: %w", err)
+		}
+
+		if len(jobs) == 0 {
+			fmt.Println("ℹ️  No jobs in Dead Letter Queue")
+			return nil
+		}
+
+		// Output as JSON
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(jobs); err != nil {
+			return fmt.Errorf("failed to encode jobs: %w", err)
+		}
+
+		return nil
+	},
+}
+
+// dlqInspectView is the JSON document printed by `queuectl dlq inspect`
+type dlqInspectView struct {
+	Job  *job.Job   `json:"job"`
+	Runs []*job.Run `json:"runs"`
+}
+
+var dlqInspectCmd = &cobra.Command{
+	Use:   "inspect [job-id]",
+	Short: "Show why a job was moved to the Dead Letter Queue",
+	Long: `Display a dead job together with its last error, exit code, stderr tail, the time it was
+dead-lettered and every attempt made to run it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jobID := args[0]
+
+		j, err := job.GetByID(jobID)
+		if err != nil {
+			return fmt.Errorf("❌ %w\n\n💡 Check the DLQ: queuectl dlq list", err)
+		}
+		if j.State != job.StateDead {
+			return fmt.Errorf("❌ Job '%s' is not in the Dead Letter Queue (state: %s)\n\n💡 Use queuectl inspect %s instead", jobID, j.State, jobID)
+		}
+
+		runs, err := job.ListRuns(jobID)
+		if err != nil {
+			return fmt.Errorf("failed to get execution history: %w", err)
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(dlqInspectView{Job: j, Runs: runs}); err != nil {
+			return fmt.Errorf("❌ Failed to encode job: %w", err)
+		}
+
+		return nil
+	},
+}
+
+var dlqRetryCmd = &cobra.Command{
+	Use:   "retry [job-id]",
+	Short: "Retry jobs from the Dead Letter Queue",
+	Long: `Move a job from the Dead Letter Queue back to pending state for retry.
+With --all or --filter, every matching dead job is retried in a single transaction.
+
+A job that depends on a dead job goes back to blocked until that job completes, so
+retry both together. A job that depends on a cancelled job, or on a dead job that is
+not retried with it, can never run: it is refused, or left in the DLQ by --all/--filter.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bulk, err := dlqBulk(cmd, args)
+		if err != nil {
+			return err
+		}
+
+		if !bulk {
+			jobID := args[0]
+			if err := job.RetryDeadJob(jobID); err != nil {
+				if errors.Is(err, job.ErrDuplicateKey) {
+					return fmt.Errorf("❌ Failed to retry job: %w\n\n💡 Let the active job with the same dedup_key finish, or cancel it first", err)
+				}
+				if errors.Is(err, job.ErrFailedDependency) {
+					return fmt.Errorf("❌ Failed to retry job: %w\n\n💡 Retry the dead job it depends on first, or both at once with --filter", err)
+				}
+				return fmt.Errorf("❌ Failed to retry job: %w\n\n💡 Make sure the job ID exists in DLQ: queuectl dlq list", err)
+			}
+			daemon.Notify()
+
+			fmt.Printf("✅ Job '%s' moved back to pending state\n", jobID)
+			return nil
+		}
+
+		filter, err := dlqFilter(cmd)
+		if err != nil {
+			return err
+		}
+
+		retried, skipped, err := job.RetryDead(filter)
+		if err != nil {
+			return fmt.Errorf("❌ Failed to retry jobs: %w", err)
+		}
+
+		if len(skipped) > 0 {
+			fmt.Printf("⚠️  %d job(s) left in the DLQ: they depend on a dead job not being retried or on a cancelled job: %s\n",
+				len(skipped), strings.Join(skipped, ", "))
+		}
+		if retried == 0 {
+			fmt.Println("ℹ️  No matching jobs in Dead Letter Queue")
+			return nil
+		}
+		daemon.Notify()
+		fmt.Printf("✅ %d job(s) moved back to the queue\n", retried)
+		return nil
+	},
+}
+
+var dlqPurgeCmd = &cobra.Command{
+	Use:   "purge [job-id]",
+	Short: "Delete jobs from the Dead Letter Queue",
+	Long: `Permanently delete a dead job together with its logs and execution history.
+With --all or --filter, every matching dead job is deleted in a single transaction.
+
+Blocked jobs that depend on a purged job can no longer run: in the same transaction
+they are cancelled or moved to the DLQ, according to their dependency_policy.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bulk, err := dlqBulk(cmd, args)
+		if err != nil {
+			return err
+		}
+
+		var filter job.DeadFilter
+		if bulk {
+			if filter, err = dlqFilter(cmd); err != nil {
+				return err
+			}
+		} else {
+			filter.ID = args[0]
+		}
+
+		purged, err := job.PurgeDead(filter)
+		if err != nil {
+			return fmt.Errorf("❌ Failed to purge jobs: %w", err)
+		}
+
+		if !bulk && purged == 0 {
+			return fmt.Errorf("❌ Job '%s' is not in the Dead Letter Queue\n\n💡 Check the DLQ: queuectl dlq list", args[0])
+		}
+		if purged == 0 {
+			fmt.Println("ℹ️  No matching jobs in Dead Letter Queue")
+			return nil
+		}
+		fmt.Printf("✅ %d job(s) purged from the Dead Letter Queue\n", purged)
+		return nil
+	},
+}
+
+var dlqRequeueCmd = &cobra.Command{
+	Use:   "requeue [job-id]",
+	Short: "Edit a dead job and move it back to the queue",
+	Long: `Fix a job in the Dead Letter Queue and requeue it with its attempts reset.
+Only the flags given are changed; --command replaces args as well.`,
+	Example: `  queuectl dlq requeue job1 --command "./backup.sh --retry" --max-retries 5`,
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jobID := args[0]
+
+		j, err := job.GetByID(jobID)
+		if err != nil {
+			return fmt.Errorf("❌ %w\n\n💡 Check the DLQ: queuectl dlq list", err)
+		}
+		if j.State != job.StateDead {
+			return fmt.Errorf("❌ Job '%s' is not in the Dead Letter Queue (state: %s)", jobID, j.State)
+		}
+
+		flags := cmd.Flags()
+		if flags.Changed("command") {
+			if j.Command, err = flags.GetString("command"); err != nil {
+				return fmt.Errorf("failed to get command flag: %w", err)
+			}
+			j.Args = nil
+		}
+		if flags.Changed("queue") {
+			if j.Queue, err = flags.GetString("queue"); err != nil {
+				return fmt.Errorf("failed to get queue flag: %w", err)
+			}
+		}
+		if flags.Changed("max-retries") {
+			if j.MaxRetries, err = flags.GetInt("max-retries"); err != nil {
+				return fmt.Errorf("failed to get max-retries flag: %w", err)
+			}
+		}
+		if flags.Changed("priority") {
+			if j.Priority, err = flags.GetInt("priority"); err != nil {
+				return fmt.Errorf("failed to get priority flag: %w", err)
+			}
+		}
+		if flags.Changed("timeout") {
+			timeout, err := flags.GetDuration("timeout")
+			if err != nil {
+				return fmt.Errorf("failed to get timeout flag: %w", err)
+			}
+			j.Timeout = job.Duration(timeout)
+		}
+
+		if err := job.Requeue(j); err != nil {
+			return fmt.Errorf("❌ Failed to requeue job: %w", err)
+		}
+		daemon.Notify()
+
+		fmt.Printf("✅ Job '%s' updated and moved back to the queue\n", jobID)
+		return nil
+	},
+}
+
+// dlqBulk reports whether a retry or purge command targets --all / --filter rather than
+// a single job id, and checks that exactly one of the two was given
+func dlqBulk(cmd *cobra.Command, args []string) (bool, error) {
+	all, err := cmd.Flags().GetBool("all")
+	if err != nil {
+		return false, fmt.Errorf("failed to get all flag: %w", err)
+	}
+	bulk := all || cmd.Flags().Changed("filter")
+
+	switch {
+	case bulk && len(args) > 0:
+		return false, fmt.Errorf("❌ Give either a job ID or --all/--filter, not both")
+	case !bulk && len(args) == 0:
+		return false, fmt.Errorf("❌ No jobs selected\n\n💡 Give a job ID, --all, or --filter key=value")
+	case all && cmd.Flags().Changed("filter"):
+		return false, fmt.Errorf("❌ --all cannot be combined with --filter")
+	}
+	return bulk, nil
+}
+
+// dlqFilter builds the DLQ selector from the --filter and --queue flags
+func dlqFilter(cmd *cobra.Command) (job.DeadFilter, error) {
+	selectors, err := cmd.Flags().GetStringArray("filter")
+	if err != nil {
+		return job.DeadFilter{}, fmt.Errorf("failed to get filter flag: %w", err)
+	}
+
+	filter, err := job.ParseDeadFilter(selectors)
+	if err != nil {
+		return filter, fmt.Errorf("❌ %w\n\n💡 Filters look like: --filter queue=emails --filter error='*timeout*' --filter since=24h", err)
+	}
+
+	if cmd.Flags().Lookup("queue") != nil {
+		queue, err := cmd.Flags().GetString("queue")
+		if err != nil {
+			return filter, fmt.Errorf("failed to get queue flag: %w", err)
+		}
+		if queue != "" {
+			filter.Queue = queue
+		}
+	}
+	return filter, nil
+}
+
+var dlqCmd = &cobra.Command{
+	Use:   "dlq",
+	Short: "Manage Dead Letter Queue",
+	Long:  `Commands for managing the Dead Letter Queue (permanently failed jobs).`,
+}
+
+func init() {
+	filterUsage := "Select dead jobs by queue=, tag=, error=, since= or until= (repeatable)"
+
+	dlqListCmd.Flags().StringP("queue", "q", "", "Only list dead jobs from this queue")
+	dlqListCmd.Flags().StringArray("filter", nil, filterUsage)
+
+	dlqRetryCmd.Flags().Bool("all", false, "Retry every job in the Dead Letter Queue")
+	dlqRetryCmd.Flags().StringArray("filter", nil, filterUsage)
+
+	dlqPurgeCmd.Flags().Bool("all", false, "Delete every job in the Dead Letter Queue")
+	dlqPurgeCmd.Flags().StringArray("filter", nil, filterUsage)
+
+	dlqRequeueCmd.Flags().String("command", "", "Replace the job's command (and clear its args)")
+	dlqRequeueCmd.Flags().StringP("queue", "q", "", "Move the job to another queue")
+	dlqRequeueCmd.Flags().Int("max-retries", 0, "Set the maximum number of retries")
+	dlqRequeueCmd.Flags().Int("priority", 0, "Set the job priority")
+	dlqRequeueCmd.Flags().Duration("timeout", 0, "Set the execution timeout (0 for the configured default)")
+
+	dlqCmd.AddCommand(dlqListCmd)
+	dlqCmd.AddCommand(dlqInspectCmd)
+	dlqCmd.AddCommand(dlqRetryCmd)
+	dlqCmd.AddCommand(dlqPurgeCmd)
+	dlqCmd.AddCommand(dlqRequeueCmd)
+	rootCmd.AddCommand(dlqCmd)
+}
+
@@ -1,43 +1,198 @@
-package cli
-
-import (
-	"fmt"
-
-	"github.com/spf13/cobra"
-	"queuectl/internal/job"
-)
-
-var enqueueCmd = &cobra.Command{
-	Use:   "enqueue [json]",
-	Short: "Add a new job to the queue",
-	Long:  `Enqueue a new job by providing a JSON string with job details.`,
-	Args:  cobra.ExactArgs(1),
-	RunE: func(cmd *cobra.Command, args []string) error {
-		jsonStr := args[0]
-
-		j, err := job.FromJSON(jsonStr)
-		if err != nil {
-			return fmt.Errorf("❌ Invalid JSON format: %w\n\n💡 Example: {\"id\":\"job1\",\"command\":\"echo hello\"}", err)
-		}
-
-		if err := job.Create(j); err != nil {
-			// Check if it's a duplicate ID error
-			if err.Error() == fmt.Sprintf("job with ID '%s' already exists", j.ID) {
-				existingJob, getErr := job.GetByID(j.ID)
-				if getErr == nil && existingJob != nil {
-					return fmt.Errorf("❌ Job with ID '%s' already exists (state: %s)\n\n💡 Solutions:\n   • Use a different job ID\n   • Check existing jobs: queuectl list\n   • Clear database: rm ~/.queuectl/queuectl.db", j.ID, existingJob.State)
-				}
-				return fmt.Errorf("❌ Job with ID '%s' already exists\n\n💡 Use a different job ID or clear the database: rm ~/.queuectl/queuectl.db", j.ID)
-			}
-			return fmt.Errorf("❌ Failed to enqueue job: %w", err)
-		}
-
-		fmt.Printf("✅ Job '%s' enqueued successfully\n", j.ID)
-		return nil
-	},
-}
-
-func init() {
-	rootCmd.AddCommand(enqueueCmd)
-}
-
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"queuectl/internal/daemon"
+	"queuectl/internal/job"
+)
+
+var enqueueCmd = &cobra.Command{
+	Use:   "enqueue [json]",
+	Short: "Add a new job to the queue",
+	Long: `Enqueue a new job by providing a JSON string with job details.
+If no "id" is given, a time-ordered ULID is generated.
+
+With --file, jobs are read one JSON object per line (NDJSON) from a file, or from stdin with "--file -".`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return fmt.Errorf("failed to get output flag: %w", err)
+		}
+		if output != "text" && output != "json" {
+			return fmt.Errorf("❌ Invalid output format: '%s'\n\n💡 Valid formats: text, json", output)
+		}
+
+		// --queue overrides the queue given in the JSON
+		queue, err := cmd.Flags().GetString("queue")
+		if err != nil {
+			return fmt.Errorf("failed to get queue flag: %w", err)
+		}
+
+		file, err := cmd.Flags().GetString("file")
+		if err != nil {
+			return fmt.Errorf("failed to get file flag: %w", err)
+		}
+		if file != "" {
+			if len(args) > 0 {
+				return fmt.Errorf("❌ Pass either a JSON argument or --file, not both")
+			}
+			return enqueueFile(cmd, file, queue, output)
+		}
+		if len(args) == 0 {
+			return fmt.Errorf("❌ Missing job JSON\n\n💡 Example: queuectl enqueue '{\"command\":\"echo hello\"}'\n   or load many jobs: queuectl enqueue --file jobs.ndjson")
+		}
+
+		j, err := job.FromJSON(args[0])
+		if err != nil {
+			return fmt.Errorf("❌ Invalid JSON format: %w\n\n💡 Example: {\"command\":\"echo hello\"}", err)
+		}
+
+		if queue != "" {
+			j.Queue = queue
+			if err := j.Validate(); err != nil {
+				return fmt.Errorf("❌ %w", err)
+			}
+		}
+
+		newID := j.ID
+		outcome, err := job.Create(j)
+		if err != nil {
+			// Check if it's a duplicate ID error
+			if errors.Is(err, job.ErrDuplicateID) {
+				existingJob, getErr := job.GetByID(j.ID)
+				if getErr == nil && existingJob != nil {
+					return fmt.Errorf("❌ Job with ID '%s' already exists (state: %s)\n\n💡 Solutions:\n   • Use a different job ID\n   • Inspect the existing job: queuectl inspect %s\n   • Set a \"dedup_key\" so enqueueing the same work twice is safe", j.ID, existingJob.State, j.ID)
+				}
+				return fmt.Errorf("❌ Job with ID '%s' already exists\n\n💡 Use a different job ID or set a \"dedup_key\"", j.ID)
+			}
+			if errors.Is(err, job.ErrDuplicateKey) {
+				return fmt.Errorf("❌ %w\n\n💡 Use \"dedup_mode\":\"existing\" to get the existing job back instead of an error", err)
+			}
+			return fmt.Errorf("❌ Failed to enqueue job: %w", err)
+		}
+		if outcome != job.OutcomeExisting {
+			daemon.Notify()
+		}
+
+		if output == "json" {
+			// Print the job as stored, with every default resolved
+			stored, err := job.GetByID(j.ID)
+			if err != nil {
+				return fmt.Errorf("failed to get enqueued job: %w", err)
+			}
+
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(enqueuedJob{Job: stored, Outcome: outcome}); err != nil {
+				return fmt.Errorf("❌ Failed to encode job: %w", err)
+			}
+			return nil
+		}
+
+		switch outcome {
+		case job.OutcomeExisting:
+			fmt.Printf("ℹ️  Job with dedup_key '%s' already queued as '%s' (state: %s), not enqueued again\n", j.DedupKey, j.ID, j.State)
+			return nil
+		case job.OutcomeReplaced:
+			fmt.Printf("✅ Job '%s' replaced the pending job with dedup_key '%s' on queue '%s'\n", newID, j.DedupKey, j.Queue)
+		default:
+			fmt.Printf("✅ Job '%s' enqueued successfully on queue '%s'\n", j.ID, j.Queue)
+		}
+		switch j.State {
+		case job.StateBlocked:
+			fmt.Println("ℹ️  Job is blocked until all of its dependencies complete")
+		case job.StateCancelled, job.StateDead:
+			fmt.Printf("⚠️  A dependency has already failed, job moved to %s\n", j.State)
+		}
+		return nil
+	},
+}
+
+// enqueuedJob is the JSON document printed by `queuectl enqueue --output json`
+type enqueuedJob struct {
+	*job.Job
+	Outcome job.Outcome `json:"outcome"`
+}
+
+// maxReportedLines caps how many duplicate or invalid lines the text summary lists
+const maxReportedLines = 20
+
+// enqueueFile bulk-enqueues the NDJSON jobs in path ("-" for stdin) and prints a summary
+func enqueueFile(cmd *cobra.Command, path, queue, output string) error {
+	atomic, err := cmd.Flags().GetBool("atomic")
+	if err != nil {
+		return fmt.Errorf("failed to get atomic flag: %w", err)
+	}
+	batchSize, err := cmd.Flags().GetInt("batch-size")
+	if err != nil {
+		return fmt.Errorf("failed to get batch-size flag: %w", err)
+	}
+	if batchSize < 1 {
+		return fmt.Errorf("❌ --batch-size must be at least 1")
+	}
+
+	var input io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("❌ Failed to open job file: %w", err)
+		}
+		defer f.Close()
+		input = f
+	}
+
+	result, err := job.CreateBatch(input, job.BatchOptions{Atomic: atomic, BatchSize: batchSize, Queue: queue})
+	if err != nil {
+		return fmt.Errorf("❌ Failed to enqueue jobs: %w", err)
+	}
+	if result.Created > 0 {
+		daemon.Notify()
+	}
+
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			return fmt.Errorf("❌ Failed to encode result: %w", err)
+		}
+	} else {
+		if result.Committed {
+			fmt.Printf("✅ Enqueued %d job(s): %d duplicate, %d invalid\n", result.Created, len(result.Duplicates), len(result.Invalid))
+		}
+		printLineResults("Duplicate", result.Duplicates)
+		printLineResults("Invalid", result.Invalid)
+	}
+
+	if !result.Committed {
+		return fmt.Errorf("❌ %d invalid line(s), nothing was enqueued\n\n💡 Fix the lines above, or drop --atomic to skip invalid lines", len(result.Invalid))
+	}
+	return nil
+}
+
+// printLineResults lists the first maxReportedLines line results under a heading
+func printLineResults(kind string, lines []job.LineResult) {
+	for i, l := range lines {
+		if i == maxReportedLines {
+			fmt.Printf("   ... and %d more\n", len(lines)-maxReportedLines)
+			break
+		}
+		fmt.Printf("   %s line %d: %s\n", kind, l.Line, l.Error)
+	}
+}
+
+func init() {
+	enqueueCmd.Flags().StringP("file", "f", "", "Read jobs from an NDJSON file, one job per line (\"-\" for stdin)")
+	enqueueCmd.Flags().Bool("atomic", false, "With --file: enqueue nothing if any line is invalid (default: skip invalid lines)")
+	enqueueCmd.Flags().Int("batch-size", job.DefaultBatchSize, "With --file: jobs per transaction when not --atomic")
+	enqueueCmd.Flags().StringP("output", "o", "text", "Output format: text or json (prints the stored job, including generated id and defaults)")
+	enqueueCmd.Flags().StringP("queue", "q", "", "Queue to put the job on (default: the job's \"queue\" field, or \"default\")")
+	rootCmd.AddCommand(enqueueCmd)
+}
+
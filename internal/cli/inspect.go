@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"queuectl/internal/job"
+)
+
+// inspectView is the JSON document printed by `queuectl inspect`
+type inspectView struct {
+	Job        *job.Job          `json:"job"`
+	Upstream   []*job.Dependency `json:"upstream"`
+	Downstream []*job.Dependency `json:"downstream"`
+	Runs       []*job.Run        `json:"runs"`
+}
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect [job-id]",
+	Short: "Show a job, its dependencies and its execution history",
+	Long: `Display a job together with the jobs it depends on (upstream), the jobs that depend on it (downstream)
+and every attempt made to run it: worker, start and finish time, duration, exit status and error.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jobID := args[0]
+
+		j, err := job.GetByID(jobID)
+		if err != nil {
+			return fmt.Errorf("❌ %w\n\n💡 Check existing jobs: queuectl list", err)
+		}
+
+		upstream, err := job.ListUpstream(jobID)
+		if err != nil {
+			return fmt.Errorf("failed to get upstream jobs: %w", err)
+		}
+
+		downstream, err := job.ListDownstream(jobID)
+		if err != nil {
+			return fmt.Errorf("failed to get downstream jobs: %w", err)
+		}
+
+		runs, err := job.ListRuns(jobID)
+		if err != nil {
+			return fmt.Errorf("failed to get execution history: %w", err)
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(inspectView{Job: j, Upstream: upstream, Downstream: downstream, Runs: runs}); err != nil {
+			return fmt.Errorf("❌ Failed to encode job: %w", err)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+}
+
@@ -1,75 +1,159 @@
-package cli
-
-import (
-	"encoding/json"
-	"fmt"
-	"os"
-
-	"github.com/spf13/cobra"
-	"queuectl/internal/job"
-)
-
-var listCmd = &cobra.Command{
-	Use:   "list",
-	Short: "List jobs by state",
-	Long:  `List all jobs filtered by state. If no state is specified, lists all jobs.`,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		stateFlag, err := cmd.Flags().GetString("state")
-		if err != nil {
-			return fmt.Errorf("failed to get state flag: %w", err)
-		}
-
-		var jobs []*job.Job
-		if stateFlag != "" {
-			// Validate state
-			validState := job.State(stateFlag)
-			if validState != job.StatePending &&
-				validState != job.StateProcessing &&
-				validState != job.StateCompleted &&
-				validState != job.StateFailed &&
-				validState != job.StateDead {
-				return fmt.Errorf("❌ Invalid state: '%s'\n\n💡 Valid states: pending, processing, completed, failed, dead", stateFlag)
-			}
-
-			jobs, err = job.ListByState(validState)
-			if err != nil {
-				return fmt.Errorf("failed to list jobs: %w", err)
-			}
-		} else {
-			// List all states
-			allStates := []job.State{
-				job.StatePending,
-				job.StateProcessing,
-				job.StateCompleted,
-				job.StateFailed,
-				job.StateDead,
-			}
-
-			for _, state := range allStates {
-				stateJobs, err := job.ListByState(state)
-				if err != nil {
-					return fmt.Errorf("failed to list jobs for state %s: %w", state, err)
-				}
-				jobs = append(jobs, stateJobs...)
-			}
-		}
-
-		// Output as JSON (always output array, even if empty)
-		if jobs == nil {
-			jobs = []*job.Job{}
-		}
-		encoder := json.NewEncoder(os.Stdout)
-		encoder.SetIndent("", "  ")
-		if err := encoder.Encode(jobs); err != nil {
-			return fmt.Errorf("❌ Failed to encode jobs: %w", err)
-		}
-
-		return nil
-	},
-}
-
-func init() {
-	listCmd.Flags().StringP("state", "s", "", "Filter jobs by state (pending, processing, completed, failed, dead)")
-	rootCmd.AddCommand(listCmd)
-}
-
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"queuectl/internal/job"
+)
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List jobs by state",
+	Long:  `List all jobs filtered by state. If no state is specified, lists all jobs.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stateFlag, err := cmd.Flags().GetString("state")
+		if err != nil {
+			return fmt.Errorf("failed to get state flag: %w", err)
+		}
+
+		queue, err := cmd.Flags().GetString("queue")
+		if err != nil {
+			return fmt.Errorf("failed to get queue flag: %w", err)
+		}
+
+		// "scheduled" is a view over pending and failed jobs that are not due yet
+		if stateFlag == "scheduled" {
+			return listScheduled(queue)
+		}
+
+		var jobs []*job.Job
+		if stateFlag != "" {
+			// Validate state
+			validState := job.State(stateFlag)
+			if !validState.Valid() {
+				return fmt.Errorf("❌ Invalid state: '%s'\n\n💡 Valid states: %s, scheduled", stateFlag, stateNames())
+			}
+
+			jobs, err = job.ListByState(validState, queue)
+			if err != nil {
+				return fmt.Errorf("failed to list jobs: %w", err)
+			}
+		} else {
+			// List all states
+			for _, state := range job.AllStates {
+				stateJobs, err := job.ListByState(state, queue)
+				if err != nil {
+					return fmt.Errorf("failed to list jobs for state %s: %w", state, err)
+				}
+				jobs = append(jobs, stateJobs...)
+			}
+		}
+
+		// Output as JSON (always output array, even if empty)
+		if jobs == nil {
+			jobs = []*job.Job{}
+		}
+
+		byPriority, err := cmd.Flags().GetBool("by-priority")
+		if err != nil {
+			return fmt.Errorf("failed to get by-priority flag: %w", err)
+		}
+
+		var output interface{} = jobs
+		if byPriority {
+			output = groupByPriority(jobs)
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(output); err != nil {
+			return fmt.Errorf("❌ Failed to encode jobs: %w", err)
+		}
+
+		return nil
+	},
+}
+
+// scheduledJob is one entry of `queuectl list --state scheduled`
+type scheduledJob struct {
+	*job.Job
+	EligibleAt time.Time `json:"eligible_at"`
+	EligibleIn string    `json:"eligible_in"`
+}
+
+// listScheduled prints jobs waiting for a future time, soonest first
+func listScheduled(queue string) error {
+	jobs, err := job.ListScheduled(queue)
+	if err != nil {
+		return fmt.Errorf("failed to list scheduled jobs: %w", err)
+	}
+
+	now := time.Now()
+	scheduled := []*scheduledJob{}
+	for _, j := range jobs {
+		eligibleAt := j.EligibleAt()
+		scheduled = append(scheduled, &scheduledJob{
+			Job:        j,
+			EligibleAt: eligibleAt,
+			EligibleIn: eligibleAt.Sub(now).Round(time.Second).String(),
+		})
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(scheduled); err != nil {
+		return fmt.Errorf("❌ Failed to encode jobs: %w", err)
+	}
+	return nil
+}
+
+// priorityGroup is one entry of `queuectl list --by-priority`
+type priorityGroup struct {
+	Priority int        `json:"priority"`
+	Count    int        `json:"count"`
+	Jobs     []*job.Job `json:"jobs"`
+}
+
+// groupByPriority groups jobs by priority, highest priority first
+func groupByPriority(jobs []*job.Job) []*priorityGroup {
+	groups := []*priorityGroup{}
+	byPriority := make(map[int]*priorityGroup)
+	for _, j := range jobs {
+		group, ok := byPriority[j.Priority]
+		if !ok {
+			group = &priorityGroup{Priority: j.Priority}
+			byPriority[j.Priority] = group
+			groups = append(groups, group)
+		}
+		group.Jobs = append(group.Jobs, j)
+		group.Count++
+	}
+
+	sort.Slice(groups, func(a, b int) bool {
+		return groups[a].Priority > groups[b].Priority
+	})
+	return groups
+}
+
+// stateNames returns the job states as a comma-separated list
+func stateNames() string {
+	names := make([]string, len(job.AllStates))
+	for i, state := range job.AllStates {
+		names[i] = string(state)
+	}
+	return strings.Join(names, ", ")
+}
+
+func init() {
+	listCmd.Flags().StringP("state", "s", "", "Filter jobs by state ("+stateNames()+"), or \"scheduled\" for jobs that are not due yet")
+	listCmd.Flags().StringP("queue", "q", "", "Only list jobs on this queue")
+	listCmd.Flags().Bool("by-priority", false, "Group jobs by priority, highest first")
+	rootCmd.AddCommand(listCmd)
+}
+
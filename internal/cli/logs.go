@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"queuectl/internal/job"
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs [job-id]",
+	Short: "Show the captured output of a job",
+	Long:  `Print the stdout (or stderr) captured while running a job. Shows the latest attempt unless --attempt is given.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jobID := args[0]
+
+		attempt, err := cmd.Flags().GetInt("attempt")
+		if err != nil {
+			return fmt.Errorf("failed to get attempt flag: %w", err)
+		}
+		if attempt < 0 {
+			return fmt.Errorf("❌ Attempt must be a positive number\n\n💡 Example: queuectl logs %s --attempt 1", jobID)
+		}
+
+		showStderr, err := cmd.Flags().GetBool("stderr")
+		if err != nil {
+			return fmt.Errorf("failed to get stderr flag: %w", err)
+		}
+
+		if _, err := job.GetByID(jobID); err != nil {
+			return fmt.Errorf("❌ %w\n\n💡 Check existing jobs: queuectl list", err)
+		}
+
+		l, err := job.GetLog(jobID, attempt)
+		if err != nil {
+			return fmt.Errorf("❌ %w\n\n💡 Logs are recorded once a worker has run the job", err)
+		}
+
+		total, err := job.CountLogs(jobID)
+		if err != nil {
+			return err
+		}
+
+		// Summary goes to stderr so the output itself can be piped
+		summary := fmt.Sprintf("Attempt %d/%d, exit code %d", l.Attempt, total, l.ExitCode)
+		if l.Signal != "" {
+			summary += fmt.Sprintf(", signal %s", l.Signal)
+		}
+		fmt.Fprintf(os.Stderr, "# %s (%s)\n", summary, l.CreatedAt.Format(time.RFC3339))
+
+		if showStderr {
+			fmt.Print(l.Stderr)
+		} else {
+			fmt.Print(l.Stdout)
+		}
+		return nil
+	},
+}
+
+func init() {
+	logsCmd.Flags().IntP("attempt", "a", 0, "Attempt number to show (default: latest)")
+	logsCmd.Flags().Bool("stderr", false, "Show stderr instead of stdout")
+	rootCmd.AddCommand(logsCmd)
+}
+
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"queuectl/internal/config"
+	"queuectl/internal/daemon"
+	"queuectl/internal/job"
+)
+
+var recoverCmd = &cobra.Command{
+	Use:   "recover",
+	Short: "Recover jobs stuck in processing",
+	Long:  `Return processing jobs whose worker lease has expired (for example because the worker was killed) to the queue. The interrupted attempt counts towards max_retries.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		recovered, err := job.ReapExpiredLeases(cfg.RetryPolicy())
+		if err != nil {
+			return fmt.Errorf("❌ Failed to recover jobs: %w", err)
+		}
+
+		if len(recovered) == 0 {
+			fmt.Println("ℹ️  No jobs with expired leases")
+			return nil
+		}
+
+		daemon.Notify()
+
+		fmt.Printf("✅ Recovered %d job(s)\n", len(recovered))
+		for _, r := range recovered {
+			owner := r.Owner
+			if owner == "" {
+				owner = "unknown worker"
+			}
+			fmt.Printf("   • %s (was held by %s) → %s after %d attempt(s)\n", r.ID, owner, r.State, r.Attempts)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(recoverCmd)
+}
+
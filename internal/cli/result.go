@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"queuectl/internal/job"
+)
+
+var resultCmd = &cobra.Command{
+	Use:   "result [job-id]",
+	Short: "Show the JSON result of a job",
+	Long: `Print the JSON a job wrote to the file named by $QUEUECTL_RESULT_FILE.
+The result is stored when the attempt that wrote it succeeds.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jobID := args[0]
+
+		j, err := job.GetByID(jobID)
+		if err != nil {
+			return fmt.Errorf("❌ %w\n\n💡 Check existing jobs: queuectl list", err)
+		}
+
+		if len(j.Result) == 0 {
+			return fmt.Errorf("❌ Job '%s' has no result (state: %s)\n\n💡 A job stores a result by writing JSON to $QUEUECTL_RESULT_FILE", jobID, j.State)
+		}
+
+		var out bytes.Buffer
+		if err := json.Indent(&out, j.Result, "", "  "); err != nil {
+			return fmt.Errorf("❌ Failed to format result: %w", err)
+		}
+		fmt.Println(out.String())
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(resultCmd)
+}
+
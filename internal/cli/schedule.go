@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"queuectl/internal/schedule"
+)
+
+var scheduleAddCmd = &cobra.Command{
+	Use:   "add [name]",
+	Short: "Add a recurring job",
+	Long: `Add a schedule that enqueues a job from a template whenever its cron expression matches.
+Jobs are enqueued by the scheduler that runs inside every worker process.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cronExpr, err := cmd.Flags().GetString("cron")
+		if err != nil {
+			return fmt.Errorf("failed to get cron flag: %w", err)
+		}
+		timezone, err := cmd.Flags().GetString("tz")
+		if err != nil {
+			return fmt.Errorf("failed to get tz flag: %w", err)
+		}
+		template, err := cmd.Flags().GetString("job")
+		if err != nil {
+			return fmt.Errorf("failed to get job flag: %w", err)
+		}
+		catchUp, err := cmd.Flags().GetString("catch-up")
+		if err != nil {
+			return fmt.Errorf("failed to get catch-up flag: %w", err)
+		}
+
+		s := &schedule.Schedule{
+			Name:        args[0],
+			Cron:        cronExpr,
+			Timezone:    timezone,
+			JobTemplate: json.RawMessage(template),
+			CatchUp:     schedule.CatchUpPolicy(catchUp),
+		}
+
+		if err := schedule.Add(s); err != nil {
+			return fmt.Errorf("❌ Failed to add schedule: %w\n\n💡 Example: queuectl schedule add nightly --cron \"0 2 * * *\" --tz Europe/Berlin --job '{\"command\":\"./backup.sh\"}'", err)
+		}
+
+		fmt.Printf("✅ Schedule '%s' added, next run at %s\n", s.Name, s.NextRunAt.Format("2006-01-02 15:04 MST"))
+		return nil
+	},
+}
+
+var scheduleListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List recurring jobs",
+	Long:  `Display all schedules with their cron expression, job template and next run time.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		schedules, err := schedule.List()
+		if err != nil {
+			return fmt.Errorf("failed to list schedules: %w", err)
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(schedules); err != nil {
+			return fmt.Errorf("❌ Failed to encode schedules: %w", err)
+		}
+		return nil
+	},
+}
+
+var scheduleRemoveCmd = &cobra.Command{
+	Use:   "remove [name]",
+	Short: "Remove a recurring job",
+	Long:  `Delete a schedule. Jobs it has already enqueued are not affected.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := schedule.Remove(args[0]); err != nil {
+			return fmt.Errorf("❌ Failed to remove schedule: %w\n\n💡 Check existing schedules: queuectl schedule list", err)
+		}
+
+		fmt.Printf("✅ Schedule '%s' removed\n", args[0])
+		return nil
+	},
+}
+
+var schedulePauseCmd = &cobra.Command{
+	Use:   "pause [name]",
+	Short: "Pause a recurring job",
+	Long:  `Stop a schedule from enqueueing jobs until it is resumed.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := schedule.Pause(args[0]); err != nil {
+			return fmt.Errorf("❌ Failed to pause schedule: %w\n\n💡 Check existing schedules: queuectl schedule list", err)
+		}
+
+		fmt.Printf("✅ Schedule '%s' paused\n", args[0])
+		return nil
+	},
+}
+
+var scheduleResumeCmd = &cobra.Command{
+	Use:   "resume [name]",
+	Short: "Resume a paused recurring job",
+	Long:  `Re-enable a paused schedule. Runs that fell inside the pause are skipped.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := schedule.Resume(args[0])
+		if err != nil {
+			return fmt.Errorf("❌ Failed to resume schedule: %w\n\n💡 Check existing schedules: queuectl schedule list", err)
+		}
+
+		fmt.Printf("✅ Schedule '%s' resumed, next run at %s\n", s.Name, s.NextRunAt.Format("2006-01-02 15:04 MST"))
+		return nil
+	},
+}
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Manage recurring jobs",
+	Long:  `Commands for managing cron-style recurring job definitions.`,
+}
+
+func init() {
+	scheduleAddCmd.Flags().String("cron", "", "Cron expression (minute hour day-of-month month day-of-week) or @hourly, @daily, ...")
+	scheduleAddCmd.Flags().String("tz", "UTC", "Timezone the cron expression is evaluated in (e.g. Europe/Berlin)")
+	scheduleAddCmd.Flags().String("job", "", "Job template as JSON, same fields as enqueue without the id")
+	scheduleAddCmd.Flags().String("catch-up", string(schedule.CatchUpOnce), "What to do with runs missed while no worker was running: skip, once or all")
+	scheduleAddCmd.MarkFlagRequired("cron")
+	scheduleAddCmd.MarkFlagRequired("job")
+
+	scheduleCmd.AddCommand(scheduleAddCmd)
+	scheduleCmd.AddCommand(scheduleListCmd)
+	scheduleCmd.AddCommand(scheduleRemoveCmd)
+	scheduleCmd.AddCommand(schedulePauseCmd)
+	scheduleCmd.AddCommand(scheduleResumeCmd)
+	rootCmd.AddCommand(scheduleCmd)
+}
+
@@ -1,44 +1,117 @@
-package cli
-
-import (
-	"fmt"
-
-	"github.com/spf13/cobra"
-	"queuectl/internal/job"
-	"queuectl/internal/worker"
-)
-
-var statusCmd = &cobra.Command{
-	Use:   "status",
-	Short: "Show summary of all job states and active workers",
-	Long:  `Display a summary of job counts by state and the number of active workers.`,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		stats, err := job.GetStats()
-		if err != nil {
-			return fmt.Errorf("failed to get job stats: %w", err)
-		}
-
-		fmt.Println("Job Queue Status")
-		fmt.Println("================")
-		fmt.Printf("Pending:   %d\n", stats[job.StatePending])
-		fmt.Printf("Processing: %d\n", stats[job.StateProcessing])
-		fmt.Printf("Completed: %d\n", stats[job.StateCompleted])
-		fmt.Printf("Failed:    %d\n", stats[job.StateFailed])
-		fmt.Printf("Dead (DLQ): %d\n", stats[job.StateDead])
-		fmt.Println()
-
-		pool := worker.GetPool()
-		if pool != nil && pool.IsRunning() {
-			fmt.Printf("Active Workers: %d\n", pool.GetWorkerCount())
-		} else {
-			fmt.Println("Active Workers: 0")
-		}
-
-		return nil
-	},
-}
-
-func init() {
-	rootCmd.AddCommand(statusCmd)
-}
-
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"queuectl/internal/job"
+	"queuectl/internal/worker"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show summary of all job states and active workers",
+	Long:  `Display a summary of job counts by state and the number of active workers.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		queue, err := cmd.Flags().GetString("queue")
+		if err != nil {
+			return fmt.Errorf("failed to get queue flag: %w", err)
+		}
+
+		stats, err := job.GetStats(queue)
+		if err != nil {
+			return fmt.Errorf("failed to get job stats: %w", err)
+		}
+
+		if queue != "" {
+			fmt.Printf("Job Queue Status (queue: %s)\n", queue)
+		} else {
+			fmt.Println("Job Queue Status")
+		}
+		fmt.Println("================")
+		fmt.Printf("Pending:   %d\n", stats[job.StatePending])
+		fmt.Printf("Blocked:   %d\n", stats[job.StateBlocked])
+		fmt.Printf("Processing: %d\n", stats[job.StateProcessing])
+		fmt.Printf("Completed: %d\n", stats[job.StateCompleted])
+		fmt.Printf("Failed:    %d\n", stats[job.StateFailed])
+		fmt.Printf("Dead (DLQ): %d\n", stats[job.StateDead])
+		fmt.Printf("Cancelled: %d\n", stats[job.StateCancelled])
+		fmt.Println()
+
+		if queue == "" {
+			queues, err := job.ListQueues()
+			if err != nil {
+				return fmt.Errorf("failed to get queue stats: %w", err)
+			}
+			if len(queues) > 0 {
+				names := make([]string, 0, len(queues))
+				for name := range queues {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+
+				fmt.Println("By Queue")
+				fmt.Println("========")
+				for _, name := range names {
+					fmt.Printf("%s: %s\n", name, formatStateCounts(queues[name]))
+				}
+				fmt.Println()
+			}
+		}
+
+		priorityStats, err := job.GetPriorityStats(queue)
+		if err != nil {
+			return fmt.Errorf("failed to get priority stats: %w", err)
+		}
+		if len(priorityStats) > 0 {
+			fmt.Println("By Priority")
+			fmt.Println("===========")
+			for _, ps := range priorityStats {
+				fmt.Printf("Priority %d: %d job(s) (%s)\n", ps.Priority, ps.Total, formatStateCounts(ps.States))
+			}
+			fmt.Println()
+		}
+
+		workers, err := worker.ListWorkers()
+		if err != nil {
+			return fmt.Errorf("failed to get workers: %w", err)
+		}
+		active, busy, stale := 0, 0, 0
+		for _, w := range workers {
+			switch {
+			case w.Stale:
+				stale++
+			case w.CurrentJob != "":
+				busy++
+				active++
+			default:
+				active++
+			}
+		}
+		fmt.Printf("Active Workers: %d (%d busy)\n", active, busy)
+		if stale > 0 {
+			fmt.Printf("⚠️  %d stale worker(s) without a recent heartbeat, see: queuectl workers\n", stale)
+		}
+
+		return nil
+	},
+}
+
+// formatStateCounts renders non-zero state counts in lifecycle order
+func formatStateCounts(counts map[job.State]int) string {
+	var parts []string
+	for _, state := range job.AllStates {
+		if counts[state] > 0 {
+			parts = append(parts, fmt.Sprintf("%s: %d", state, counts[state]))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func init() {
+	statusCmd.Flags().StringP("queue", "q", "", "Only count jobs on this queue")
+	rootCmd.AddCommand(statusCmd)
+}
+
@@ -1,85 +1,303 @@
-package cli
-
-import (
-	"fmt"
-	"os"
-	"os/signal"
-	"syscall"
-
-	"github.com/spf13/cobra"
-	"queuectl/internal/config"
-	"queuectl/internal/worker"
-)
-
-var workerStartCmd = &cobra.Command{
-	Use:   "start",
-	Short: "Start worker processes",
-	Long:  `Start one or more worker processes to process jobs from the queue.`,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		count, err := cmd.Flags().GetInt("count")
-		if err != nil {
-			return fmt.Errorf("failed to get count flag: %w", err)
-		}
-
-		if count < 1 {
-			return fmt.Errorf("❌ Worker count must be at least 1\n\n💡 Example: queuectl worker start --count 2")
-		}
-
-		if err := worker.StartPool(count); err != nil {
-			return fmt.Errorf("❌ Failed to start workers: %w\n\n💡 Make sure workers aren't already running: queuectl worker stop", err)
-		}
-
-		fmt.Printf("✅ Started %d worker(s)\n", count)
-
-		// Set up signal handling for graceful shutdown
-		sigChan := make(chan os.Signal, 1)
-		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-
-		// Wait for interrupt signal
-		<-sigChan
-		fmt.Println("\nShutting down workers...")
-
-		if err := worker.StopPool(); err != nil {
-			return fmt.Errorf("failed to stop workers: %w", err)
-		}
-
-		fmt.Println("Workers stopped")
-		return nil
-	},
-}
-
-var workerStopCmd = &cobra.Command{
-	Use:   "stop",
-	Short: "Stop running workers",
-	Long:  `Stop all running worker processes gracefully.`,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := worker.StopPool(); err != nil {
-			return fmt.Errorf("❌ Failed to stop workers: %w\n\n💡 Workers may not be running. Check status: queuectl status", err)
-		}
-
-		fmt.Println("✅ Workers stopped")
-		return nil
-	},
-}
-
-var workerCmd = &cobra.Command{
-	Use:   "worker",
-	Short: "Manage worker processes",
-	Long:  `Commands for managing worker processes.`,
-}
-
-func init() {
-	// Set default from config
-	cfg, err := config.Load()
-	defaultCount := 1
-	if err == nil {
-		defaultCount = cfg.WorkerCount
-	}
-	
-	workerStartCmd.Flags().IntP("count", "c", defaultCount, "Number of workers to start")
-
-	workerCmd.AddCommand(workerStartCmd)
-	workerCmd.AddCommand(workerStopCmd)
-	rootCmd.AddCommand(workerCmd)
-}
-
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"queuectl/internal/config"
+	"queuectl/internal/daemon"
+	"queuectl/internal/worker"
+)
+
+var workerStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Start worker processes",
+	Long: `Start one or more worker processes to process jobs from the queue.
+With --detach the workers run in the background as a daemon that queuectl worker stop can reach.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		count, err := cmd.Flags().GetInt("count")
+		if err != nil {
+			return fmt.Errorf("failed to get count flag: %w", err)
+		}
+
+		if count < 1 {
+			return fmt.Errorf("❌ Worker count must be at least 1\n\n💡 Example: queuectl worker start --count 2")
+		}
+
+		queuesFlag, err := cmd.Flags().GetString("queues")
+		if err != nil {
+			return fmt.Errorf("failed to get queues flag: %w", err)
+		}
+		queues, err := worker.ParseQueueSubscriptions(queuesFlag)
+		if err != nil {
+			return fmt.Errorf("❌ Invalid --queues value: %w\n\n💡 Example: queuectl worker start --queues reports:3,emails:1", err)
+		}
+
+		detach, err := cmd.Flags().GetBool("detach")
+		if err != nil {
+			return fmt.Errorf("failed to get detach flag: %w", err)
+		}
+		if detach {
+			return startDaemon(count, queuesFlag)
+		}
+
+		daemonized, err := cmd.Flags().GetBool("daemonized")
+		if err != nil {
+			return fmt.Errorf("failed to get daemonized flag: %w", err)
+		}
+		// Set up signal handling for graceful shutdown before anything is started,
+		// so a signal arriving during startup still stops the pool cleanly
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(sigChan)
+
+		if daemonized {
+			// Held until the pool has stopped so worker stop can wait on it
+			lock, err := daemon.Acquire()
+			if err != nil {
+				return fmt.Errorf("❌ %w", err)
+			}
+			defer lock.Release()
+			signal.Ignore(syscall.SIGHUP)
+		}
+
+		if err := worker.StartPool(count, queues); err != nil {
+			return fmt.Errorf("❌ Failed to start workers: %w\n\n💡 Make sure workers aren't already running: queuectl worker stop", err)
+		}
+
+		fmt.Printf("✅ Started %d worker(s) on %s\n", count, worker.GetPool().Queues())
+
+		if daemonized {
+			ln, err := daemon.Listen()
+			if err != nil {
+				worker.StopPool()
+				return fmt.Errorf("❌ %w", err)
+			}
+			defer ln.Close()
+			go daemon.Serve(ln, worker.GetPool())
+		}
+
+		// Wait for interrupt signal
+		<-sigChan
+		fmt.Println("\nShutting down workers...")
+
+		if err := worker.StopPool(); err != nil {
+			return fmt.Errorf("failed to stop workers: %w", err)
+		}
+
+		fmt.Println("Workers stopped")
+		return nil
+	},
+}
+
+// startDaemon re-runs worker start in the background and reports where it is running
+func startDaemon(count int, queues string) error {
+	args := []string{"worker", "start", "--count", strconv.Itoa(count), "--daemonized"}
+	if queues != "" {
+		args = append(args, "--queues", queues)
+	}
+
+	pid, err := daemon.Start(args)
+	if err != nil {
+		return fmt.Errorf("❌ Failed to start worker daemon: %w\n\n💡 Stop the running daemon first: queuectl worker stop", err)
+	}
+
+	logPath, err := daemon.LogFile()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ Started worker daemon with %d worker(s) (pid %d)\n", count, pid)
+	fmt.Printf("ℹ️  Logs: %s\n", logPath)
+	return nil
+}
+
+var workerStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the background worker daemon",
+	Long: `Ask the worker daemon started with worker start --detach to shut down gracefully.
+Running jobs are allowed to finish. If the daemon has not exited within --timeout,
+the command fails, or with --force kills it; jobs it was running are then recovered
+once their lease expires.
+
+--force kills only the daemon. Job commands run in their own process groups and are
+left running; stop them yourself, or a recovered job runs again alongside them.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		timeout, err := cmd.Flags().GetDuration("timeout")
+		if err != nil {
+			return fmt.Errorf("failed to get timeout flag: %w", err)
+		}
+		force, err := cmd.Flags().GetBool("force")
+		if err != nil {
+			return fmt.Errorf("failed to get force flag: %w", err)
+		}
+
+		pid, err := daemon.Running()
+		if err != nil {
+			return fmt.Errorf("❌ Failed to stop workers: %w\n\n💡 Start one with: queuectl worker start --detach", err)
+		}
+
+		fmt.Printf("Stopping worker daemon (pid %d)...\n", pid)
+		err = daemon.Stop(pid, timeout)
+		if errors.Is(err, daemon.ErrTimeout) && force {
+			fmt.Printf("⚠️  Daemon did not stop within %s, killing it\n", timeout)
+			fmt.Println("⚠️  Commands of the jobs it was running keep running in their own process groups")
+			err = daemon.Kill(pid)
+		}
+		if errors.Is(err, daemon.ErrTimeout) {
+			return fmt.Errorf("❌ %w (it may still be finishing jobs)\n\n💡 Wait longer with --timeout, or kill it with --force", err)
+		}
+		if err != nil {
+			return fmt.Errorf("❌ Failed to stop workers: %w", err)
+		}
+
+		fmt.Println("✅ Workers stopped")
+		return nil
+	},
+}
+
+var workerStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the state of the worker daemon",
+	Long:  `Query the worker daemon over its control socket: worker count, running jobs and whether claiming is paused.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := sendControl(daemon.Request{Command: daemon.CommandStatus})
+		if err != nil {
+			return err
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(resp.Status); err != nil {
+			return fmt.Errorf("failed to encode status: %w", err)
+		}
+		return nil
+	},
+}
+
+var workerScaleCmd = &cobra.Command{
+	Use:   "scale [count]",
+	Short: "Change the number of workers in the daemon",
+	Long: `Scale the worker daemon up or down without restarting it.
+Workers removed by scaling down finish their current job first.`,
+	Example: `  queuectl worker scale 8`,
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		count, err := strconv.Atoi(args[0])
+		if err != nil || count < 1 {
+			return fmt.Errorf("❌ Worker count must be a number of at least 1\n\n💡 Example: queuectl worker scale 4")
+		}
+
+		if _, err := sendControl(daemon.Request{Command: daemon.CommandScale, Count: count}); err != nil {
+			return err
+		}
+
+		fmt.Printf("✅ Worker daemon scaled to %d worker(s)\n", count)
+		return nil
+	},
+}
+
+var workerPauseCmd = &cobra.Command{
+	Use:   "pause",
+	Short: "Stop the worker daemon from claiming new jobs",
+	Long:  `Pause claiming in the worker daemon. Running jobs carry on; resume with queuectl worker resume.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := sendControl(daemon.Request{Command: daemon.CommandPause})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("✅ Worker daemon paused (%d job(s) still running)\n", resp.Status.Busy)
+		return nil
+	},
+}
+
+var workerResumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Let the worker daemon claim jobs again",
+	Long:  `Resume claiming in a paused or drained worker daemon.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := sendControl(daemon.Request{Command: daemon.CommandResume}); err != nil {
+			return err
+		}
+
+		fmt.Println("✅ Worker daemon resumed")
+		return nil
+	},
+}
+
+var workerDrainCmd = &cobra.Command{
+	Use:   "drain",
+	Short: "Pause the worker daemon and wait for running jobs to finish",
+	Long: `Stop the worker daemon from claiming new jobs and wait until every running job has finished.
+The daemon keeps running, paused; resume it with queuectl worker resume.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		timeout, err := cmd.Flags().GetDuration("timeout")
+		if err != nil {
+			return fmt.Errorf("failed to get timeout flag: %w", err)
+		}
+
+		fmt.Println("Draining worker daemon...")
+		if _, err := sendControl(daemon.Request{Command: daemon.CommandDrain, Timeout: timeout}); err != nil {
+			return err
+		}
+
+		fmt.Println("✅ Worker daemon drained, no jobs running")
+		return nil
+	},
+}
+
+// sendControl sends a request to the worker daemon and turns failures into CLI errors
+func sendControl(req daemon.Request) (*daemon.Response, error) {
+	resp, err := daemon.Send(req)
+	if errors.Is(err, daemon.ErrNotRunning) {
+		return nil, fmt.Errorf("❌ %w\n\n💡 Start one with: queuectl worker start --detach", err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("❌ Worker daemon: %w", err)
+	}
+	return resp, nil
+}
+
+var workerCmd = &cobra.Command{
+	Use:   "worker",
+	Short: "Manage worker processes",
+	Long:  `Commands for managing worker processes.`,
+}
+
+func init() {
+	// Set default from config
+	cfg, err := config.Load()
+	defaultCount := 1
+	if err == nil {
+		defaultCount = cfg.WorkerCount
+	}
+
+	workerStartCmd.Flags().IntP("count", "c", defaultCount, "Number of workers to start")
+	workerStartCmd.Flags().StringP("queues", "q", "", "Comma-separated queues to claim from, optionally weighted (e.g. reports:3,emails:1); default: all queues")
+
+	workerStartCmd.Flags().BoolP("detach", "d", false, "Run the workers in the background as a daemon")
+	workerStartCmd.Flags().Bool("daemonized", false, "Run as the daemon started by --detach")
+	workerStartCmd.Flags().MarkHidden("daemonized")
+
+	workerStopCmd.Flags().Duration("timeout", 30*time.Second, "How long to wait for running jobs to finish")
+	workerStopCmd.Flags().Bool("force", false, "Kill the daemon if it has not stopped within --timeout")
+
+	workerDrainCmd.Flags().Duration("timeout", 0, "How long to wait for running jobs (0 waits until they finish)")
+
+	workerCmd.AddCommand(workerStartCmd)
+	workerCmd.AddCommand(workerStopCmd)
+	workerCmd.AddCommand(workerStatusCmd)
+	workerCmd.AddCommand(workerScaleCmd)
+	workerCmd.AddCommand(workerPauseCmd)
+	workerCmd.AddCommand(workerResumeCmd)
+	workerCmd.AddCommand(workerDrainCmd)
+	rootCmd.AddCommand(workerCmd)
+}
+
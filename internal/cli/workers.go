@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"queuectl/internal/worker"
+)
+
+var workersCmd = &cobra.Command{
+	Use:   "workers",
+	Short: "List registered workers",
+	Long: `Display every worker registered by a running worker process: host, pid, start time,
+queues, current job and last heartbeat. Workers whose heartbeat is overdue, such as those
+of a process that was killed, are marked stale; remove them with --prune.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		prune, err := cmd.Flags().GetBool("prune")
+		if err != nil {
+			return fmt.Errorf("failed to get prune flag: %w", err)
+		}
+
+		if prune {
+			pruned, err := worker.PruneStaleWorkers()
+			if err != nil {
+				return fmt.Errorf("❌ Failed to prune workers: %w", err)
+			}
+			fmt.Printf("✅ Removed %d stale worker(s)\n", pruned)
+			return nil
+		}
+
+		workers, err := worker.ListWorkers()
+		if err != nil {
+			return fmt.Errorf("failed to list workers: %w", err)
+		}
+
+		if len(workers) == 0 {
+			fmt.Println("ℹ️  No workers registered")
+			return nil
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(workers); err != nil {
+			return fmt.Errorf("failed to encode workers: %w", err)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	workersCmd.Flags().Bool("prune", false, "Remove stale workers from the registry")
+	rootCmd.AddCommand(workersCmd)
+}
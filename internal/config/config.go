@@ -1,158 +1,407 @@
-package config
-
-import (
-	"encoding/json"
-	"fmt"
-	"os"
-	"path/filepath"
-)
-
-const (
-	KeyMaxRetries  = "max-retries"
-	KeyBackoffBase = "backoff-base"
-	KeyWorkerCount = "worker-count"
-)
-
-type Config struct {
-	MaxRetries  int     `json:"max-retries"`
-	BackoffBase float64 `json:"backoff-base"`
-	WorkerCount int     `json:"worker-count"`
-}
-
-var defaultConfig = Config{
-	MaxRetries:  3,
-	BackoffBase: 2.0,
-	WorkerCount: 1,
-}
-
-// getConfigPath returns the path to the config file
-func getConfigPath() (string, error) {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("failed to get home directory: %w", err)
-	}
-
-	queuectlDir := filepath.Join(homeDir, ".queuectl")
-	if err := os.MkdirAll(queuectlDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create .queuectl directory: %w", err)
-	}
-
-	return filepath.Join(queuectlDir, "config.json"), nil
-}
-
-// Load loads the configuration from file
-func Load() (*Config, error) {
-	configPath, err := getConfigPath()
-	if err != nil {
-		return nil, err
-	}
-
-	// If config file doesn't exist, return default config
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		return &defaultConfig, nil
-	}
-
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
-	}
-
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
-	}
-
-	// Merge with defaults for missing values
-	if config.MaxRetries == 0 {
-		config.MaxRetries = defaultConfig.MaxRetries
-	}
-	if config.BackoffBase == 0 {
-		config.BackoffBase = defaultConfig.BackoffBase
-	}
-	if config.WorkerCount == 0 {
-		config.WorkerCount = defaultConfig.WorkerCount
-	}
-
-	return &config, nil
-}
-
-// Save saves the configuration to file
-func Save(config *Config) error {
-	configPath, err := getConfigPath()
-	if err != nil {
-		return err
-	}
-
-	data, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
-	}
-
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write config file: %w", err)
-	}
-
-	return nil
-}
-
-// Get returns a configuration value by key
-func Get(key string) (string, error) {
-	config, err := Load()
-	if err != nil {
-		return "", err
-	}
-
-	switch key {
-	case KeyMaxRetries:
-		return fmt.Sprintf("%d", config.MaxRetries), nil
-	case KeyBackoffBase:
-		return fmt.Sprintf("%.2f", config.BackoffBase), nil
-	case KeyWorkerCount:
-		return fmt.Sprintf("%d", config.WorkerCount), nil
-	default:
-		return "", fmt.Errorf("unknown config key: %s", key)
-	}
-}
-
-// Set sets a configuration value by key
-func Set(key, value string) error {
-	config, err := Load()
-	if err != nil {
-		return err
-	}
-
-	switch key {
-	case KeyMaxRetries:
-		var maxRetries int
-		if _, err := fmt.Sscanf(value, "%d", &maxRetries); err != nil {
-			return fmt.Errorf("invalid value for max-retries: '%s' (must be a number)", value)
-		}
-		if maxRetries < 0 {
-			return fmt.Errorf("max-retries must be non-negative (got: %d)", maxRetries)
-		}
-		config.MaxRetries = maxRetries
-	case KeyBackoffBase:
-		var backoffBase float64
-		if _, err := fmt.Sscanf(value, "%f", &backoffBase); err != nil {
-			return fmt.Errorf("invalid value for backoff-base: '%s' (must be a number)", value)
-		}
-		if backoffBase <= 0 {
-			return fmt.Errorf("backoff-base must be positive (got: %.2f)", backoffBase)
-		}
-		config.BackoffBase = backoffBase
-	case KeyWorkerCount:
-		var workerCount int
-		if _, err := fmt.Sscanf(value, "%d", &workerCount); err != nil {
-			return fmt.Errorf("invalid value for worker-count: '%s' (must be a number)", value)
-		}
-		if workerCount < 1 {
-			return fmt.Errorf("worker-count must be at least 1 (got: %d)", workerCount)
-		}
-		config.WorkerCount = workerCount
-	default:
-		return fmt.Errorf("unknown config key: %s", key)
-	}
-
-	return Save(config)
-}
-
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"queuectl/internal/job"
+)
+
+const (
+	KeyMaxRetries    = "max-retries"
+	KeyBackoffBase   = "backoff-base"
+	KeyWorkerCount   = "worker-count"
+	KeyJobTimeout    = "job-timeout"
+	KeyLeaseDuration = "lease-duration"
+	KeyPriorityAging = "priority-aging"
+	KeyRetryStrategy = "retry-strategy"
+	KeyRetryDelay    = "retry-delay"
+	KeyRetryMinDelay = "retry-min-delay"
+	KeyRetryMaxDelay = "retry-max-delay"
+	KeyExitRules     = "exit-rules"
+	KeyPollInterval  = "poll-interval"
+)
+
+type Config struct {
+	MaxRetries    int     `json:"max-retries"`
+	BackoffBase   float64 `json:"backoff-base"`
+	WorkerCount   int     `json:"worker-count"`
+	JobTimeout    int     `json:"job-timeout"`    // seconds, 0 = no timeout
+	LeaseDuration int     `json:"lease-duration"` // seconds
+	PriorityAging int     `json:"priority-aging"` // seconds of waiting per priority point, 0 = off
+	RetryStrategy string  `json:"retry-strategy"`
+	RetryDelay    int     `json:"retry-delay"`     // seconds, 0 = backoff-base seconds
+	RetryMinDelay int     `json:"retry-min-delay"` // seconds
+	RetryMaxDelay int     `json:"retry-max-delay"` // seconds
+	PollInterval  int     `json:"poll-interval"`   // milliseconds an idle worker waits at most between checks for jobs
+
+	// ExitRules apply to every job, after the job's own exit rules
+	ExitRules []job.ExitRule `json:"exit-rules,omitempty"`
+}
+
+var defaultConfig = Config{
+	MaxRetries:    3,
+	BackoffBase:   2.0,
+	WorkerCount:   1,
+	LeaseDuration: 30,
+	RetryStrategy: string(job.RetryExponential),
+	RetryMaxDelay: 3600,
+	PollInterval:  1000,
+}
+
+// getConfigPath returns the path to the config file
+func getConfigPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	queuectlDir := filepath.Join(homeDir, ".queuectl")
+	if err := os.MkdirAll(queuectlDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create .queuectl directory: %w", err)
+	}
+
+	return filepath.Join(queuectlDir, "config.json"), nil
+}
+
+// Load loads the configuration from file
+// Values edited by hand are checked against the same limits config set enforces.
+func Load() (*Config, error) {
+	config, err := load()
+	if err != nil {
+		return nil, err
+	}
+	if err := config.validate(); err != nil {
+		configPath, _ := getConfigPath()
+		return nil, fmt.Errorf("invalid config file %s: %w", configPath, err)
+	}
+	return config, nil
+}
+
+// load reads the configuration file and fills in defaults without validating it,
+// so that config get and config set still work on a file with a bad value
+func load() (*Config, error) {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	// If config file doesn't exist, return default config
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		return &defaultConfig, nil
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	// Merge with defaults for missing values
+	if config.MaxRetries == 0 {
+		config.MaxRetries = defaultConfig.MaxRetries
+	}
+	if config.BackoffBase == 0 {
+		config.BackoffBase = defaultConfig.BackoffBase
+	}
+	if config.WorkerCount == 0 {
+		config.WorkerCount = defaultConfig.WorkerCount
+	}
+	if config.LeaseDuration == 0 {
+		config.LeaseDuration = defaultConfig.LeaseDuration
+	}
+	if config.RetryStrategy == "" {
+		config.RetryStrategy = defaultConfig.RetryStrategy
+	}
+	if config.RetryMaxDelay == 0 {
+		config.RetryMaxDelay = defaultConfig.RetryMaxDelay
+	}
+	if config.PollInterval == 0 {
+		config.PollInterval = defaultConfig.PollInterval
+	}
+
+	return &config, nil
+}
+
+// validate applies the limits config set enforces to every value
+func (c *Config) validate() error {
+	switch {
+	case c.MaxRetries < 0:
+		return fmt.Errorf("max-retries must be non-negative (got: %d)", c.MaxRetries)
+	case c.BackoffBase <= 0:
+		return fmt.Errorf("backoff-base must be positive (got: %.2f)", c.BackoffBase)
+	case c.WorkerCount < 1:
+		return fmt.Errorf("worker-count must be at least 1 (got: %d)", c.WorkerCount)
+	case c.JobTimeout < 0:
+		return fmt.Errorf("job-timeout must be non-negative (got: %d)", c.JobTimeout)
+	case c.LeaseDuration < 3:
+		return fmt.Errorf("lease-duration must be at least 3 seconds (got: %d)", c.LeaseDuration)
+	case c.PriorityAging < 0:
+		return fmt.Errorf("priority-aging must be non-negative (got: %d)", c.PriorityAging)
+	case c.RetryDelay < 0:
+		return fmt.Errorf("retry-delay must be non-negative (got: %d)", c.RetryDelay)
+	case c.RetryMinDelay < 0:
+		return fmt.Errorf("retry-min-delay must be non-negative (got: %d)", c.RetryMinDelay)
+	case c.RetryMaxDelay < 1:
+		return fmt.Errorf("retry-max-delay must be at least 1 second (got: %d)", c.RetryMaxDelay)
+	case c.PollInterval < 10:
+		return fmt.Errorf("poll-interval must be at least 10ms (got: %dms)", c.PollInterval)
+	}
+
+	policy := job.RetryPolicy{Strategy: job.RetryStrategy(c.RetryStrategy)}
+	if err := policy.Validate(); err != nil {
+		return fmt.Errorf("invalid retry-strategy: '%s' (must be fixed, linear, exponential or exponential-jitter)", c.RetryStrategy)
+	}
+	for i := range c.ExitRules {
+		if err := c.ExitRules[i].Validate(); err != nil {
+			return fmt.Errorf("invalid exit-rules: %w", err)
+		}
+	}
+	return nil
+}
+
+// Save saves the configuration to file
+func Save(config *Config) error {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	if err := os.WriteFile(configPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns a configuration value by key
+func Get(key string) (string, error) {
+	config, err := load()
+	if err != nil {
+		return "", err
+	}
+
+	switch key {
+	case KeyMaxRetries:
+		return fmt.Sprintf("%d", config.MaxRetries), nil
+	case KeyBackoffBase:
+		return fmt.Sprintf("%.2f", config.BackoffBase), nil
+	case KeyWorkerCount:
+		return fmt.Sprintf("%d", config.WorkerCount), nil
+	case KeyJobTimeout:
+		return (time.Duration(config.JobTimeout) * time.Second).String(), nil
+	case KeyLeaseDuration:
+		return config.LeaseDurationValue().String(), nil
+	case KeyPriorityAging:
+		return config.PriorityAgingValue().String(), nil
+	case KeyRetryStrategy:
+		return config.RetryStrategy, nil
+	case KeyRetryDelay:
+		return (time.Duration(config.RetryDelay) * time.Second).String(), nil
+	case KeyRetryMinDelay:
+		return (time.Duration(config.RetryMinDelay) * time.Second).String(), nil
+	case KeyRetryMaxDelay:
+		return (time.Duration(config.RetryMaxDelay) * time.Second).String(), nil
+	case KeyPollInterval:
+		return config.PollIntervalValue().String(), nil
+	case KeyExitRules:
+		rules := config.ExitRules
+		if rules == nil {
+			rules = []job.ExitRule{}
+		}
+		data, err := json.Marshal(rules)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal exit rules: %w", err)
+		}
+		return string(data), nil
+	default:
+		return "", fmt.Errorf("unknown config key: %s", key)
+	}
+}
+
+// Set sets a configuration value by key
+func Set(key, value string) error {
+	config, err := load()
+	if err != nil {
+		return err
+	}
+
+	switch key {
+	case KeyMaxRetries:
+		var maxRetries int
+		if _, err := fmt.Sscanf(value, "%d", &maxRetries); err != nil {
+			return fmt.Errorf("invalid value for max-retries: '%s' (must be a number)", value)
+		}
+		if maxRetries < 0 {
+			return fmt.Errorf("max-retries must be non-negative (got: %d)", maxRetries)
+		}
+		config.MaxRetries = maxRetries
+	case KeyBackoffBase:
+		var backoffBase float64
+		if _, err := fmt.Sscanf(value, "%f", &backoffBase); err != nil {
+			return fmt.Errorf("invalid value for backoff-base: '%s' (must be a number)", value)
+		}
+		if backoffBase <= 0 {
+			return fmt.Errorf("backoff-base must be positive (got: %.2f)", backoffBase)
+		}
+		config.BackoffBase = backoffBase
+	case KeyWorkerCount:
+		var workerCount int
+		if _, err := fmt.Sscanf(value, "%d", &workerCount); err != nil {
+			return fmt.Errorf("invalid value for worker-count: '%s' (must be a number)", value)
+		}
+		if workerCount < 1 {
+			return fmt.Errorf("worker-count must be at least 1 (got: %d)", workerCount)
+		}
+		config.WorkerCount = workerCount
+	case KeyJobTimeout:
+		timeout, err := parseSeconds(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for job-timeout: '%s' (must be a duration in whole seconds like 30s, or a number of seconds)", value)
+		}
+		if timeout < 0 {
+			return fmt.Errorf("job-timeout must be non-negative (got: %s)", value)
+		}
+		config.JobTimeout = timeout
+	case KeyLeaseDuration:
+		lease, err := parseSeconds(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for lease-duration: '%s' (must be a duration in whole seconds like 30s, or a number of seconds)", value)
+		}
+		if lease < 3 {
+			return fmt.Errorf("lease-duration must be at least 3 seconds (got: %s)", value)
+		}
+		config.LeaseDuration = lease
+	case KeyPriorityAging:
+		aging, err := parseSeconds(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for priority-aging: '%s' (must be a duration in whole seconds like 5m, or a number of seconds)", value)
+		}
+		if aging < 0 {
+			return fmt.Errorf("priority-aging must be non-negative (got: %s)", value)
+		}
+		config.PriorityAging = aging
+	case KeyRetryStrategy:
+		policy := job.RetryPolicy{Strategy: job.RetryStrategy(value)}
+		if err := policy.Validate(); err != nil || value == "" {
+			return fmt.Errorf("invalid value for retry-strategy: '%s' (must be fixed, linear, exponential or exponential-jitter)", value)
+		}
+		config.RetryStrategy = value
+	case KeyRetryDelay, KeyRetryMinDelay, KeyRetryMaxDelay:
+		delay, err := parseSeconds(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: '%s' (must be a duration in whole seconds like 30s, or a number of seconds)", key, value)
+		}
+		if delay < 0 {
+			return fmt.Errorf("%s must be non-negative (got: %s)", key, value)
+		}
+		switch key {
+		case KeyRetryDelay:
+			config.RetryDelay = delay
+		case KeyRetryMinDelay:
+			config.RetryMinDelay = delay
+		case KeyRetryMaxDelay:
+			if delay == 0 {
+				return fmt.Errorf("retry-max-delay must be at least 1 second (got: %s)", value)
+			}
+			config.RetryMaxDelay = delay
+		}
+	case KeyPollInterval:
+		interval, err := parseMillis(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for poll-interval: '%s' (must be a duration like 500ms or a number of seconds)", value)
+		}
+		if interval < 10 {
+			return fmt.Errorf("poll-interval must be at least 10ms (got: %s)", value)
+		}
+		config.PollInterval = interval
+	case KeyExitRules:
+		var rules []job.ExitRule
+		if err := json.Unmarshal([]byte(value), &rules); err != nil {
+			return fmt.Errorf("invalid value for exit-rules: must be a JSON array like '[{\"exit_codes\":[75],\"action\":\"tempfail\"}]'")
+		}
+		for i := range rules {
+			if err := rules[i].Validate(); err != nil {
+				return fmt.Errorf("invalid value for exit-rules: %w", err)
+			}
+		}
+		config.ExitRules = rules
+	default:
+		return fmt.Errorf("unknown config key: %s", key)
+	}
+
+	return Save(config)
+}
+
+// JobTimeoutDuration returns the default job timeout, or 0 if jobs may run forever
+func (c *Config) JobTimeoutDuration() time.Duration {
+	return time.Duration(c.JobTimeout) * time.Second
+}
+
+// LeaseDurationValue returns how long a claimed job stays leased without a heartbeat
+func (c *Config) LeaseDurationValue() time.Duration {
+	return time.Duration(c.LeaseDuration) * time.Second
+}
+
+// PriorityAgingValue returns how long a job must wait to gain one priority point, or 0 if aging is off
+func (c *Config) PriorityAgingValue() time.Duration {
+	return time.Duration(c.PriorityAging) * time.Second
+}
+
+// PollIntervalValue returns the longest an idle worker waits before checking for jobs again
+func (c *Config) PollIntervalValue() time.Duration {
+	return time.Duration(c.PollInterval) * time.Millisecond
+}
+
+// RetryPolicy returns the retry policy for jobs that do not set their own
+func (c *Config) RetryPolicy() job.RetryPolicy {
+	return job.RetryPolicy{
+		Strategy: job.RetryStrategy(c.RetryStrategy),
+		Delay:    job.Duration(time.Duration(c.RetryDelay) * time.Second),
+		Base:     c.BackoffBase,
+		MinDelay: job.Duration(time.Duration(c.RetryMinDelay) * time.Second),
+		MaxDelay: job.Duration(time.Duration(c.RetryMaxDelay) * time.Second),
+	}
+}
+
+// parseSeconds parses a Go duration string ("90s", "5m") or a plain number of seconds
+// Durations that are not a whole number of seconds ("500ms", "1.5s") are rejected
+// rather than truncated, so a short job-timeout cannot silently become 0 (no timeout).
+func parseSeconds(value string) (int, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return seconds, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d%time.Second != 0 {
+		return 0, fmt.Errorf("%s is not a whole number of seconds", value)
+	}
+	return int(d / time.Second), nil
+}
+
+// parseMillis parses a number of seconds or a Go duration string into milliseconds
+func parseMillis(value string) (int, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return seconds * 1000, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	return int(d / time.Millisecond), nil
+}
@@ -0,0 +1,192 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"queuectl/internal/worker"
+)
+
+// Commands understood by the control socket
+const (
+	CommandStatus = "status"
+	CommandScale  = "scale"
+	CommandPause  = "pause"
+	CommandResume = "resume"
+	CommandDrain  = "drain"
+	CommandCancel = "cancel"
+	CommandWake   = "wake"
+)
+
+// requestTimeout bounds how long a client waits for a reply, on top of a drain's own timeout
+const requestTimeout = 5 * time.Second
+
+// Request is one command sent to the daemon over its control socket
+type Request struct {
+	Command string        `json:"command"`
+	Count   int           `json:"count,omitempty"`   // scale
+	JobID   string        `json:"job_id,omitempty"`  // cancel
+	Timeout time.Duration `json:"timeout,omitempty"` // drain; 0 waits as long as it takes
+}
+
+// Response is the daemon's reply to a Request
+type Response struct {
+	OK     bool               `json:"ok"`
+	Error  string             `json:"error,omitempty"`
+	Status *worker.PoolStatus `json:"status,omitempty"`
+}
+
+// SocketPath returns the path of the daemon's control socket
+func SocketPath() (string, error) {
+	d, err := dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d, "worker.sock"), nil
+}
+
+// Listen creates the control socket, replacing one left behind by a daemon that died
+// Only call it while holding the daemon lock.
+func Listen() (net.Listener, error) {
+	path, err := SocketPath()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove stale control socket: %w", err)
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create control socket: %w", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to restrict control socket: %w", err)
+	}
+	return ln, nil
+}
+
+// Serve answers requests on ln with the state of pool until ln is closed
+// Each connection carries one JSON request and gets one JSON response.
+func Serve(ln net.Listener, pool *worker.Pool) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			fmt.Printf("Control: Failed to accept connection: %v\n", err)
+			continue
+		}
+		go serveConn(conn, pool)
+	}
+}
+
+// serveConn handles a single control connection
+func serveConn(conn net.Conn, pool *worker.Pool) {
+	defer conn.Close()
+
+	var req Request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		json.NewEncoder(conn).Encode(Response{Error: fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+
+	resp := handle(req, pool)
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		fmt.Printf("Control: Failed to send response: %v\n", err)
+	}
+}
+
+// handle applies a request to the pool
+func handle(req Request, pool *worker.Pool) Response {
+	var err error
+	switch req.Command {
+	case CommandStatus:
+	case CommandScale:
+		if err = pool.Scale(req.Count); err == nil {
+			fmt.Printf("Control: Scaled to %d worker(s)\n", req.Count)
+		}
+	case CommandPause:
+		pool.Pause()
+		fmt.Println("Control: Paused claiming")
+	case CommandResume:
+		pool.Resume()
+		fmt.Println("Control: Resumed claiming")
+	case CommandDrain:
+		ctx := context.Background()
+		if req.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, req.Timeout)
+			defer cancel()
+		}
+		fmt.Println("Control: Draining")
+		err = pool.Drain(ctx)
+	case CommandWake:
+		pool.Wake()
+	case CommandCancel:
+		if err = pool.CancelJob(req.JobID); err == nil {
+			fmt.Printf("Control: Cancelling job %s\n", req.JobID)
+		}
+	default:
+		err = fmt.Errorf("unknown command '%s'", req.Command)
+	}
+
+	status := pool.Status()
+	if err != nil {
+		return Response{Error: err.Error(), Status: &status}
+	}
+	return Response{OK: true, Status: &status}
+}
+
+// Send sends a request to the running daemon and returns its response
+// It returns ErrNotRunning if no daemon is listening.
+func Send(req Request) (*Response, error) {
+	path, err := SocketPath()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("unix", path, requestTimeout)
+	if err != nil {
+		return nil, ErrNotRunning
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(requestTimeout)
+	if req.Command == CommandDrain {
+		if req.Timeout == 0 {
+			deadline = time.Time{}
+		} else {
+			deadline = deadline.Add(req.Timeout)
+		}
+	}
+	conn.SetDeadline(deadline)
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if !resp.OK {
+		return &resp, errors.New(resp.Error)
+	}
+	return &resp, nil
+}
+
+// Notify tells the running daemon that jobs are ready so idle workers claim them right away
+// It does nothing if no daemon is running; workers then find the jobs on their next poll.
+func Notify() {
+	Send(Request{Command: CommandWake})
+}
@@ -0,0 +1,67 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// startTimeout is how long Start waits for the daemon to take its lock
+const startTimeout = 5 * time.Second
+
+var (
+	// ErrNotRunning is returned when no worker daemon holds the lock
+	ErrNotRunning = errors.New("worker daemon is not running")
+	// ErrTimeout is returned when the daemon did not exit in time
+	ErrTimeout = errors.New("timed out waiting for the worker daemon to stop")
+)
+
+// dir returns ~/.queuectl, creating it if needed
+func dir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	queuectlDir := filepath.Join(homeDir, ".queuectl")
+	if err := os.MkdirAll(queuectlDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create .queuectl directory: %w", err)
+	}
+	return queuectlDir, nil
+}
+
+// PIDFile returns the path of the daemon's PID/lock file
+func PIDFile() (string, error) {
+	d, err := dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d, "worker.pid"), nil
+}
+
+// LogFile returns the path the daemon's output is appended to
+func LogFile() (string, error) {
+	d, err := dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d, "worker.log"), nil
+}
+
+// readPID reads the PID written to the lock file
+func readPID(f *os.File) (int, error) {
+	data := make([]byte, 32)
+	n, err := f.ReadAt(data, 0)
+	if n == 0 && err != nil {
+		return 0, fmt.Errorf("failed to read PID file: %w", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data[:n])))
+	if err != nil {
+		return 0, fmt.Errorf("invalid PID file: %w", err)
+	}
+	return pid, nil
+}
@@ -0,0 +1,167 @@
+//go:build !windows
+
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Lock is held by a running daemon for as long as it runs
+type Lock struct {
+	file *os.File
+}
+
+// Acquire takes the daemon lock and writes the current PID to the lock file
+// It fails if another daemon already holds it.
+func Acquire() (*Lock, error) {
+	path, err := PIDFile()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PID file: %w", err)
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		pid, _ := readPID(f)
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("worker daemon is already running (pid %d)", pid)
+		}
+		return nil, fmt.Errorf("failed to lock PID file: %w", err)
+	}
+
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write PID file: %w", err)
+	}
+	if _, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write PID file: %w", err)
+	}
+
+	return &Lock{file: f}, nil
+}
+
+// Release clears the PID and releases the lock
+// The file itself is kept: removing it while locked would let another daemon
+// lock a new file at the same path while this one still holds the old one.
+func (l *Lock) Release() {
+	l.file.Truncate(0)
+	syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	l.file.Close()
+}
+
+// Running returns the PID of the running daemon, or ErrNotRunning
+// A PID file left behind by a daemon that died is ignored, since its lock is gone.
+func Running() (int, error) {
+	path, err := PIDFile()
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, ErrNotRunning
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open PID file: %w", err)
+	}
+	defer f.Close()
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_SH|syscall.LOCK_NB); err == nil {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		return 0, ErrNotRunning
+	}
+	return readPID(f)
+}
+
+// Start runs this executable with args as a detached daemon and waits until it holds the lock
+// The daemon gets its own session, and its output is appended to LogFile.
+func Start(args []string) (int, error) {
+	if pid, err := Running(); err == nil {
+		return 0, fmt.Errorf("worker daemon is already running (pid %d)", pid)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("failed to find executable: %w", err)
+	}
+
+	logPath, err := LogFile()
+	if err != nil {
+		return 0, err
+	}
+	logFile, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(exe, args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("failed to start daemon: %w", err)
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	deadline := time.After(startTimeout)
+	for {
+		if pid, err := Running(); err == nil && pid == cmd.Process.Pid {
+			return pid, nil
+		}
+
+		select {
+		case err := <-exited:
+			return 0, fmt.Errorf("daemon exited during startup (%v), see %s", err, logPath)
+		case <-deadline:
+			return 0, fmt.Errorf("daemon did not start within %s, see %s", startTimeout, logPath)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// Stop asks the daemon with the given PID to shut down gracefully (SIGTERM)
+// and waits up to timeout for it to exit
+func Stop(pid int, timeout time.Duration) error {
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+		return fmt.Errorf("failed to signal daemon: %w", err)
+	}
+	return wait(pid, timeout)
+}
+
+// Kill stops the daemon with the given PID immediately (SIGKILL)
+// Jobs it was running are recovered by the lease reaper.
+func Kill(pid int) error {
+	if err := syscall.Kill(pid, syscall.SIGKILL); err != nil {
+		return fmt.Errorf("failed to kill daemon: %w", err)
+	}
+	return wait(pid, startTimeout)
+}
+
+// wait polls until the daemon with the given PID no longer holds the lock
+func wait(pid int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		running, err := Running()
+		if errors.Is(err, ErrNotRunning) || (err == nil && running != pid) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return ErrTimeout
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
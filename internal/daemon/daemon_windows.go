@@ -0,0 +1,42 @@
+//go:build windows
+
+package daemon
+
+import (
+	"errors"
+	"time"
+)
+
+// errUnsupported is returned by every daemon operation on Windows
+var errUnsupported = errors.New("worker daemon mode is not supported on Windows")
+
+// Lock is held by a running daemon for as long as it runs
+type Lock struct{}
+
+// Acquire is not supported on Windows
+func Acquire() (*Lock, error) {
+	return nil, errUnsupported
+}
+
+// Release does nothing on Windows
+func (l *Lock) Release() {}
+
+// Running always reports that no daemon is running on Windows
+func Running() (int, error) {
+	return 0, ErrNotRunning
+}
+
+// Start is not supported on Windows
+func Start(args []string) (int, error) {
+	return 0, errUnsupported
+}
+
+// Stop is not supported on Windows
+func Stop(pid int, timeout time.Duration) error {
+	return errUnsupported
+}
+
+// Kill is not supported on Windows
+func Kill(pid int) error {
+	return errUnsupported
+}
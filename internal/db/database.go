@@ -1,62 +1,68 @@
-package db
-
-import (
-	"database/sql"
-	"fmt"
-	"os"
-	"path/filepath"
-
-	_ "modernc.org/sqlite"
-)
-
-var DB *sql.DB
-
-// Init initializes the SQLite database connection and creates the schema
-func Init() error {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("failed to get home directory: %w", err)
-	}
-
-	queuectlDir := filepath.Join(homeDir, ".queuectl")
-	if err := os.MkdirAll(queuectlDir, 0755); err != nil {
-		return fmt.Errorf("failed to create .queuectl directory: %w", err)
-	}
-
-	dbPath := filepath.Join(queuectlDir, "queuectl.db")
-	
-	db, err := sql.Open("sqlite", dbPath)
-	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
-	}
-
-	// Enable foreign keys, set WAL mode for better concurrency, and set busy timeout
-	// busy_timeout sets how long SQLite will wait for a lock (in milliseconds)
-	// 5000ms = 5 seconds
-	if _, err := db.Exec("PRAGMA foreign_keys = ON; PRAGMA journal_mode = WAL; PRAGMA busy_timeout = 5000;"); err != nil {
-		return fmt.Errorf("failed to set database pragmas: %w", err)
-	}
-
-	DB = db
-
-	// Create schema
-	if err := createSchema(); err != nil {
-		return fmt.Errorf("failed to create schema: %w", err)
-	}
-
-	return nil
-}
-
-// Close closes the database connection
-func Close() error {
-	if DB != nil {
-		return DB.Close()
-	}
-	return nil
-}
-
-// GetDB returns the database connection
-func GetDB() *sql.DB {
-	return DB
-}
-
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	_ "modernc.org/sqlite"
+)
+
+var DB *sql.DB
+
+// Init initializes the SQLite database connection and creates the schema
+func Init() error {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	queuectlDir := filepath.Join(homeDir, ".queuectl")
+	if err := os.MkdirAll(queuectlDir, 0755); err != nil {
+		return fmt.Errorf("failed to create .queuectl directory: %w", err)
+	}
+
+	dbPath := filepath.Join(queuectlDir, "queuectl.db")
+
+	// Enable foreign keys, set WAL mode for better concurrency, and set busy timeout
+	// busy_timeout sets how long SQLite will wait for a lock (in milliseconds)
+	// 5000ms = 5 seconds
+	// The pragmas go in the DSN so that every pooled connection gets them, not just the first
+	// Every transaction here writes, so each takes the write lock when it begins:
+	// a lookup followed by a write (dedup keys, claiming a job) then cannot race
+	// another transaction, and contention waits on busy_timeout instead of failing
+	dsn := "file:" + dbPath + "?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_txlock=immediate"
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		return fmt.Errorf("failed to set database pragmas: %w", err)
+	}
+
+	DB = db
+
+	// Create schema
+	if err := createSchema(); err != nil {
+		return fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the database connection
+func Close() error {
+	if DB != nil {
+		return DB.Close()
+	}
+	return nil
+}
+
+// GetDB returns the database connection
+func GetDB() *sql.DB {
+	return DB
+}
+
@@ -1,37 +1,251 @@
-package db
-
-import (
-	"fmt"
-)
-
-// createSchema creates the database tables
-func createSchema() error {
-	// Create jobs table
-	jobsTableSQL := `
-	CREATE TABLE IF NOT EXISTS jobs (
-		id TEXT PRIMARY KEY,
-		command TEXT NOT NULL,
-		state TEXT NOT NULL DEFAULT 'pending',
-		attempts INTEGER NOT NULL DEFAULT 0,
-		max_retries INTEGER NOT NULL DEFAULT 3,
-		created_at TEXT NOT NULL,
-		updated_at TEXT NOT NULL,
-		next_retry_at TEXT
-	);`
-
-	if _, err := DB.Exec(jobsTableSQL); err != nil {
-		return fmt.Errorf("failed to create jobs table: %w", err)
-	}
-
-	// Create index on state for faster queries
-	indexSQL := `
-	CREATE INDEX IF NOT EXISTS idx_jobs_state ON jobs(state);
-	CREATE INDEX IF NOT EXISTS idx_jobs_next_retry_at ON jobs(next_retry_at);`
-
-	if _, err := DB.Exec(indexSQL); err != nil {
-		return fmt.Errorf("failed to create indexes: %w", err)
-	}
-
-	return nil
-}
-
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// createSchema creates the database tables
+func createSchema() error {
+	// Create jobs table
+	jobsTableSQL := `
+	CREATE TABLE IF NOT EXISTS jobs (
+		id TEXT PRIMARY KEY,
+		queue TEXT NOT NULL DEFAULT 'default',
+		tags TEXT,
+		command TEXT NOT NULL,
+		args TEXT,
+		env TEXT,
+		cwd TEXT NOT NULL DEFAULT '',
+		payload TEXT,
+		payload_mode TEXT NOT NULL DEFAULT '',
+		result TEXT,
+		state TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		max_retries INTEGER NOT NULL DEFAULT 3,
+		priority INTEGER NOT NULL DEFAULT 0,
+		timeout_ms INTEGER NOT NULL DEFAULT 0,
+		retry_policy TEXT,
+		exit_rules TEXT,
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL,
+		next_retry_at TEXT,
+		lease_owner TEXT,
+		lease_expires_at TEXT,
+		cancel_requested INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT,
+		last_exit_code INTEGER,
+		stderr_tail TEXT,
+		dead_at TEXT,
+		run_at TEXT,
+		dependency_policy TEXT NOT NULL DEFAULT '',
+		dedup_key TEXT
+	);`
+
+	if _, err := DB.Exec(jobsTableSQL); err != nil {
+		return fmt.Errorf("failed to create jobs table: %w", err)
+	}
+
+	// Bring jobs tables created by older versions up to date
+	jobsColumns := []struct {
+		name       string
+		definition string
+	}{
+		{"timeout_ms", "INTEGER NOT NULL DEFAULT 0"},
+		{"lease_owner", "TEXT"},
+		{"lease_expires_at", "TEXT"},
+		{"priority", "INTEGER NOT NULL DEFAULT 0"},
+		{"queue", "TEXT NOT NULL DEFAULT 'default'"},
+		{"run_at", "TEXT"},
+		{"dependency_policy", "TEXT NOT NULL DEFAULT ''"},
+		{"dedup_key", "TEXT"},
+		{"args", "TEXT"},
+		{"env", "TEXT"},
+		{"cwd", "TEXT NOT NULL DEFAULT ''"},
+		{"payload", "TEXT"},
+		{"payload_mode", "TEXT NOT NULL DEFAULT ''"},
+		{"result", "TEXT"},
+		{"cancel_requested", "INTEGER NOT NULL DEFAULT 0"},
+		{"retry_policy", "TEXT"},
+		{"exit_rules", "TEXT"},
+		{"last_error", "TEXT"},
+		{"last_exit_code", "INTEGER"},
+		{"stderr_tail", "TEXT"},
+		{"dead_at", "TEXT"},
+		{"tags", "TEXT"},
+	}
+	for _, column := range jobsColumns {
+		if err := addColumnIfMissing("jobs", column.name, column.definition); err != nil {
+			return err
+		}
+	}
+
+	// Create job_logs table holding the captured output of every attempt
+	jobLogsTableSQL := `
+	CREATE TABLE IF NOT EXISTS job_logs (
+		job_id TEXT NOT NULL,
+		attempt INTEGER NOT NULL,
+		stdout TEXT NOT NULL DEFAULT '',
+		stderr TEXT NOT NULL DEFAULT '',
+		exit_code INTEGER NOT NULL DEFAULT -1,
+		signal TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL,
+		PRIMARY KEY (job_id, attempt),
+		FOREIGN KEY (job_id) REFERENCES jobs(id) ON DELETE CASCADE
+	);`
+
+	if _, err := DB.Exec(jobLogsTableSQL); err != nil {
+		return fmt.Errorf("failed to create job_logs table: %w", err)
+	}
+
+	// Create job_runs table with one row per attempt
+	jobRunsTableSQL := `
+	CREATE TABLE IF NOT EXISTS job_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		job_id TEXT NOT NULL,
+		attempt INTEGER NOT NULL,
+		worker_id TEXT NOT NULL,
+		started_at TEXT NOT NULL,
+		finished_at TEXT,
+		duration_ms INTEGER NOT NULL DEFAULT 0,
+		status TEXT NOT NULL,
+		exit_code INTEGER,
+		error TEXT NOT NULL DEFAULT '',
+		FOREIGN KEY (job_id) REFERENCES jobs(id) ON DELETE CASCADE
+	);`
+
+	if _, err := DB.Exec(jobRunsTableSQL); err != nil {
+		return fmt.Errorf("failed to create job_runs table: %w", err)
+	}
+
+	// Create job_dependencies table with one row per edge of the job graph
+	jobDependenciesTableSQL := `
+	CREATE TABLE IF NOT EXISTS job_dependencies (
+		job_id TEXT NOT NULL,
+		depends_on TEXT NOT NULL,
+		PRIMARY KEY (job_id, depends_on),
+		FOREIGN KEY (job_id) REFERENCES jobs(id) ON DELETE CASCADE
+	);`
+
+	if _, err := DB.Exec(jobDependenciesTableSQL); err != nil {
+		return fmt.Errorf("failed to create job_dependencies table: %w", err)
+	}
+
+	// Create schedules table holding recurring job definitions
+	schedulesTableSQL := `
+	CREATE TABLE IF NOT EXISTS schedules (
+		name TEXT PRIMARY KEY,
+		cron TEXT NOT NULL,
+		timezone TEXT NOT NULL DEFAULT 'UTC',
+		job_template TEXT NOT NULL,
+		catch_up TEXT NOT NULL DEFAULT 'once',
+		paused INTEGER NOT NULL DEFAULT 0,
+		next_run_at TEXT NOT NULL,
+		last_run_at TEXT,
+		created_at TEXT NOT NULL
+	);`
+
+	if _, err := DB.Exec(schedulesTableSQL); err != nil {
+		return fmt.Errorf("failed to create schedules table: %w", err)
+	}
+
+	// Create workers table where running workers register and heartbeat
+	workersTableSQL := `
+	CREATE TABLE IF NOT EXISTS workers (
+		id TEXT PRIMARY KEY,
+		host TEXT NOT NULL,
+		pid INTEGER NOT NULL,
+		queues TEXT NOT NULL,
+		current_job TEXT,
+		started_at TEXT NOT NULL,
+		last_heartbeat TEXT NOT NULL
+	);`
+
+	if _, err := DB.Exec(workersTableSQL); err != nil {
+		return fmt.Errorf("failed to create workers table: %w", err)
+	}
+
+	// Create index on state for faster queries
+	indexSQL := `
+	CREATE INDEX IF NOT EXISTS idx_jobs_state ON jobs(state);
+	CREATE INDEX IF NOT EXISTS idx_jobs_next_retry_at ON jobs(next_retry_at);
+	CREATE INDEX IF NOT EXISTS idx_jobs_lease_expires_at ON jobs(state, lease_expires_at);
+	CREATE INDEX IF NOT EXISTS idx_jobs_priority ON jobs(state, priority DESC, created_at);
+	CREATE INDEX IF NOT EXISTS idx_jobs_queue ON jobs(queue, state);
+	CREATE INDEX IF NOT EXISTS idx_jobs_run_at ON jobs(state, run_at);
+	CREATE INDEX IF NOT EXISTS idx_jobs_dedup_key ON jobs(dedup_key) WHERE dedup_key IS NOT NULL;
+	CREATE INDEX IF NOT EXISTS idx_job_runs_job_id ON job_runs(job_id, attempt);
+	CREATE INDEX IF NOT EXISTS idx_job_dependencies_depends_on ON job_dependencies(depends_on);`
+
+	if _, err := DB.Exec(indexSQL); err != nil {
+		return fmt.Errorf("failed to create indexes: %w", err)
+	}
+
+	if err := createDedupIndex(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// activeStates lists the states in which a job holds its dedup_key exclusively
+const activeStates = `'pending', 'blocked', 'processing', 'failed'`
+
+// createDedupIndex allows at most one active job per dedup_key, so two enqueues
+// of the same key cannot both insert a job. Keys shared by active jobs in a
+// database from before the index are cleared on all but the oldest of them.
+func createDedupIndex() error {
+	var exists int
+	if err := DB.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'idx_jobs_dedup_key_active'`).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check dedup index: %w", err)
+	}
+	if exists > 0 {
+		return nil
+	}
+
+	dedupSQL := `
+	UPDATE jobs SET dedup_key = NULL
+	WHERE dedup_key IS NOT NULL AND state IN (` + activeStates + `)
+	  AND EXISTS (
+		SELECT 1 FROM jobs o
+		WHERE o.dedup_key = jobs.dedup_key AND o.state IN (` + activeStates + `)
+		  AND (o.created_at < jobs.created_at OR (o.created_at = jobs.created_at AND o.id < jobs.id)));
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_dedup_key_active ON jobs(dedup_key)
+	WHERE dedup_key IS NOT NULL AND state IN (` + activeStates + `);`
+
+	if _, err := DB.Exec(dedupSQL); err != nil {
+		return fmt.Errorf("failed to create dedup index: %w", err)
+	}
+	return nil
+}
+
+// addColumnIfMissing adds a column to an existing table unless it is already there
+func addColumnIfMissing(table, column, definition string) error {
+	rows, err := DB.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to read %s columns: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return fmt.Errorf("failed to scan %s columns: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read %s columns: %w", table, err)
+	}
+	rows.Close()
+
+	if _, err := DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add %s.%s column: %w", table, column, err)
+	}
+	return nil
+}
+
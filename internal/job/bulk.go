@@ -0,0 +1,154 @@
+package job
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"queuectl/internal/db"
+)
+
+// DefaultBatchSize is how many jobs CreateBatch inserts per transaction
+const DefaultBatchSize = 1000
+
+// maxLineBytes caps the length of a single NDJSON line
+const maxLineBytes = 16 * 1024 * 1024
+
+// BatchOptions controls CreateBatch
+type BatchOptions struct {
+	// Atomic inserts every job in a single transaction and inserts nothing if any line is invalid.
+	// Otherwise invalid lines are skipped and jobs are committed every BatchSize lines.
+	Atomic    bool
+	BatchSize int
+	// Queue, if set, overrides the queue of every job
+	Queue string
+}
+
+// LineResult describes a line of the input that was not enqueued as a new job
+type LineResult struct {
+	Line  int    `json:"line"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error"`
+}
+
+// BatchResult summarizes a CreateBatch run
+type BatchResult struct {
+	Created    int          `json:"created"`
+	Duplicates []LineResult `json:"duplicates"`
+	Invalid    []LineResult `json:"invalid"`
+	// Committed is false when an atomic run was rolled back
+	Committed bool `json:"committed"`
+}
+
+// CreateBatch enqueues one job per non-empty line of r (NDJSON)
+// Lines whose id or dedup_key is already taken count as duplicates; lines that
+// fail to parse or validate count as invalid. Both are reported with their line number.
+func CreateBatch(r io.Reader, opts BatchOptions) (*BatchResult, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+
+	result := &BatchResult{Duplicates: []LineResult{}, Invalid: []LineResult{}}
+
+	var tx *sql.Tx
+	defer func() {
+		if tx != nil {
+			tx.Rollback()
+		}
+	}()
+
+	inBatch := 0
+	commit := func() error {
+		if tx == nil {
+			return nil
+		}
+		err := tx.Commit()
+		tx = nil
+		inBatch = 0
+		if err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineBytes)
+
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		j, err := FromJSON(line)
+		if err == nil && opts.Queue != "" {
+			j.Queue = opts.Queue
+			err = j.Validate()
+		}
+		if err != nil {
+			result.Invalid = append(result.Invalid, LineResult{Line: lineNo, Error: err.Error()})
+			continue
+		}
+
+		if tx == nil {
+			if tx, err = db.GetDB().Begin(); err != nil {
+				return nil, fmt.Errorf("failed to begin transaction: %w", err)
+			}
+		}
+
+		// A savepoint per line lets a failed insert be undone without losing the batch
+		if _, err := tx.Exec(`SAVEPOINT enqueue_line`); err != nil {
+			return nil, fmt.Errorf("failed to create savepoint: %w", err)
+		}
+		id := j.ID
+		outcome, err := CreateTx(tx, j)
+		if err != nil {
+			if _, rbErr := tx.Exec(`ROLLBACK TO enqueue_line`); rbErr != nil {
+				return nil, fmt.Errorf("failed to roll back line %d: %w", lineNo, rbErr)
+			}
+			if errors.Is(err, ErrDuplicateID) || errors.Is(err, ErrDuplicateKey) {
+				result.Duplicates = append(result.Duplicates, LineResult{Line: lineNo, ID: id, Error: err.Error()})
+			} else {
+				result.Invalid = append(result.Invalid, LineResult{Line: lineNo, ID: id, Error: err.Error()})
+			}
+		} else if outcome == OutcomeExisting {
+			result.Duplicates = append(result.Duplicates, LineResult{
+				Line:  lineNo,
+				ID:    id,
+				Error: fmt.Sprintf("dedup_key '%s' already queued as '%s'", j.DedupKey, j.ID),
+			})
+		} else {
+			result.Created++
+		}
+		if _, err := tx.Exec(`RELEASE enqueue_line`); err != nil {
+			return nil, fmt.Errorf("failed to release savepoint: %w", err)
+		}
+
+		inBatch++
+		if !opts.Atomic && inBatch >= opts.BatchSize {
+			if err := commit(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read input after line %d: %w", lineNo, err)
+	}
+
+	if opts.Atomic && len(result.Invalid) > 0 {
+		// Nothing from this run is kept
+		result.Created = 0
+		return result, nil
+	}
+
+	if err := commit(); err != nil {
+		return nil, err
+	}
+	result.Committed = true
+	return result, nil
+}
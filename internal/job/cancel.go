@@ -0,0 +1,92 @@
+package job
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"queuectl/internal/db"
+)
+
+// ErrNotCancellable is returned by Cancel for jobs that have already finished
+var ErrNotCancellable = errors.New("job cannot be cancelled")
+
+// Cancel cancels a job and returns the state it was in
+// Pending (including scheduled), blocked and failed jobs are cancelled at once
+// and their dependents resolved. For a processing job a cancellation request is
+// recorded; the worker running it kills the command and records the cancellation.
+func Cancel(id string) (State, error) {
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	j, err := GetByIDTx(tx, id)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	var result sql.Result
+	switch j.State {
+	case StatePending, StateBlocked, StateFailed:
+		result, err = tx.Exec(
+			`UPDATE jobs SET state = ?, next_retry_at = NULL, updated_at = ? WHERE id = ? AND state = ?`,
+			string(StateCancelled), now, id, string(j.State),
+		)
+	case StateProcessing:
+		result, err = tx.Exec(
+			`UPDATE jobs SET cancel_requested = 1, updated_at = ? WHERE id = ? AND state = ?`,
+			now, id, string(StateProcessing),
+		)
+	default:
+		return j.State, fmt.Errorf("job %s is already %s: %w", id, j.State, ErrNotCancellable)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to cancel job: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return "", fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return "", fmt.Errorf("job %s changed state while cancelling, try again", id)
+	}
+
+	if j.State != StateProcessing {
+		if err := ResolveDependents(tx, id, StateCancelled); err != nil {
+			return "", err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return j.State, nil
+}
+
+// CancelRequested reports whether cancellation of a processing job has been requested
+func CancelRequested(id string) (bool, error) {
+	return cancelRequested(db.GetDB().QueryRow(`SELECT cancel_requested FROM jobs WHERE id = ?`, id))
+}
+
+// CancelRequestedTx reports within a transaction whether cancellation of a job has been requested
+func CancelRequestedTx(tx *sql.Tx, id string) (bool, error) {
+	return cancelRequested(tx.QueryRow(`SELECT cancel_requested FROM jobs WHERE id = ?`, id))
+}
+
+// cancelRequested reads the cancel_requested flag selected by row
+func cancelRequested(row *sql.Row) (bool, error) {
+	var requested bool
+	if err := row.Scan(&requested); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check for cancellation: %w", err)
+	}
+	return requested, nil
+}
+
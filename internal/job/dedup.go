@@ -0,0 +1,111 @@
+package job
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DedupMode decides what Create does when a job with the same dedup_key is already queued
+type DedupMode string
+
+const (
+	// DedupExisting leaves the existing job alone and returns it (the default)
+	DedupExisting DedupMode = "existing"
+	// DedupReject fails with ErrDuplicateKey
+	DedupReject DedupMode = "reject"
+	// DedupReplace swaps out the existing job if it has not started yet,
+	// and otherwise behaves like DedupExisting
+	DedupReplace DedupMode = "replace"
+)
+
+// ErrDuplicateKey is returned by Create in reject mode when the dedup_key is taken
+var ErrDuplicateKey = errors.New("duplicate dedup key")
+
+// errActiveDedupKey reports an insert or update refused by the unique index that
+// allows one active job per dedup_key
+var errActiveDedupKey = errors.New("dedup key is held by an active job")
+
+// Outcome says what Create did with a job
+type Outcome string
+
+const (
+	OutcomeCreated  Outcome = "created"
+	OutcomeExisting Outcome = "existing" // an equivalent job was already queued
+	OutcomeReplaced Outcome = "replaced" // an equivalent job that had not started was replaced
+)
+
+// findDuplicate returns the newest job sharing j's dedup_key that is still active,
+// or completed within j's dedup window. It returns nil if there is none.
+func findDuplicate(tx *sql.Tx, j *Job) (*Job, error) {
+	query := `
+		SELECT ` + jobColumns + `
+		FROM jobs
+		WHERE dedup_key = ?
+		  AND (state IN (?, ?, ?, ?) OR (state = ? AND updated_at >= ?))
+		ORDER BY created_at DESC
+		LIMIT 1`
+
+	// Without a window only active jobs match
+	cutoff := "9999"
+	if j.DedupWindow > 0 {
+		cutoff = time.Now().Add(-time.Duration(j.DedupWindow)).Format(time.RFC3339)
+	}
+
+	existing, err := scanJob(tx.QueryRow(
+		query,
+		j.DedupKey,
+		string(StatePending),
+		string(StateBlocked),
+		string(StateProcessing),
+		string(StateFailed),
+		string(StateCompleted),
+		cutoff,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up dedup key: %w", err)
+	}
+	return existing, nil
+}
+
+// dedup looks for a job sharing j's dedup_key and applies j.DedupMode to it
+// done is true when j must not be inserted: the outcome is then final, and
+// with OutcomeExisting *j has been overwritten with the existing job.
+func dedup(tx *sql.Tx, j *Job) (outcome Outcome, done bool, err error) {
+	existing, err := findDuplicate(tx, j)
+	if err != nil {
+		return "", true, err
+	}
+	if existing == nil {
+		return OutcomeCreated, false, nil
+	}
+
+	switch {
+	case j.DedupMode == DedupReject:
+		return "", true, fmt.Errorf("job with dedup_key '%s' already exists as '%s' (state: %s): %w", j.DedupKey, existing.ID, existing.State, ErrDuplicateKey)
+	case j.DedupMode == DedupReplace && (existing.State == StatePending || existing.State == StateBlocked):
+		if err := replaceJob(tx, existing, j); err != nil {
+			return "", true, err
+		}
+		return OutcomeReplaced, false, nil
+	default:
+		*j = *existing
+		return OutcomeExisting, true, nil
+	}
+}
+
+// replaceJob deletes a job that has not started yet so that replacement can take its place
+// Jobs depending on the old job are pointed at the replacement.
+func replaceJob(tx *sql.Tx, old, replacement *Job) error {
+	if _, err := tx.Exec(`UPDATE job_dependencies SET depends_on = ? WHERE depends_on = ?`, replacement.ID, old.ID); err != nil {
+		return fmt.Errorf("failed to move dependencies of %s: %w", old.ID, err)
+	}
+	if _, err := tx.Exec(`DELETE FROM jobs WHERE id = ?`, old.ID); err != nil {
+		return fmt.Errorf("failed to replace job %s: %w", old.ID, err)
+	}
+	return nil
+}
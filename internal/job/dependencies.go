@@ -0,0 +1,206 @@
+package job
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"queuectl/internal/db"
+)
+
+// DependencyPolicy decides what happens to a blocked job when a job it depends on fails for good
+type DependencyPolicy string
+
+const (
+	// DependencyCancel moves the job to cancelled (the default)
+	DependencyCancel DependencyPolicy = "cancel"
+	// DependencyDead moves the job to the DLQ so it can be retried later
+	DependencyDead DependencyPolicy = "dead"
+)
+
+// Dependency is one edge of the dependency graph as seen from a job
+type Dependency struct {
+	ID    string `json:"id"`
+	State State  `json:"state"`
+}
+
+// failedState returns the state a blocked job moves to when a parent is dead or cancelled
+func (p DependencyPolicy) failedState() State {
+	if p == DependencyDead {
+		return StateDead
+	}
+	return StateCancelled
+}
+
+// dependencyState works out the state a new job starts in from the states of its parents:
+// blocked while any parent is unfinished, or the policy's failed state if a parent is dead or cancelled
+// Every parent must already exist, which also rules out cycles.
+func dependencyState(tx *sql.Tx, j *Job) (State, error) {
+	query := `SELECT id, state FROM jobs WHERE id IN (?` + strings.Repeat(", ?", len(j.DependsOn)-1) + `)`
+	args := make([]interface{}, len(j.DependsOn))
+	for i, parent := range j.DependsOn {
+		args[i] = parent
+	}
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up dependencies: %w", err)
+	}
+	defer rows.Close()
+
+	parents := make(map[string]State)
+	for rows.Next() {
+		var id, state string
+		if err := rows.Scan(&id, &state); err != nil {
+			return "", fmt.Errorf("failed to scan dependency: %w", err)
+		}
+		parents[id] = State(state)
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("failed to look up dependencies: %w", err)
+	}
+
+	state := j.State
+	for _, parent := range j.DependsOn {
+		parentState, ok := parents[parent]
+		if !ok {
+			return "", fmt.Errorf("dependency not found: %s", parent)
+		}
+		switch parentState {
+		case StateCompleted:
+		case StateDead, StateCancelled:
+			return j.DependencyPolicy.failedState(), nil
+		default:
+			state = StateBlocked
+		}
+	}
+	return state, nil
+}
+
+// insertDependencies records the edges from a new job to the jobs it depends on
+func insertDependencies(tx *sql.Tx, j *Job) error {
+	for _, parent := range j.DependsOn {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO job_dependencies (job_id, depends_on) VALUES (?, ?)`, j.ID, parent); err != nil {
+			return fmt.Errorf("failed to save dependency on %s: %w", parent, err)
+		}
+	}
+	return nil
+}
+
+// ResolveDependents updates the blocked jobs that depend on a job which just reached state
+// When the job completed, dependents whose parents have all completed become pending.
+// When it is dead or cancelled, blocked dependents move to the state their policy asks for,
+// and the same happens to their own dependents in turn.
+func ResolveDependents(tx *sql.Tx, id string, state State) error {
+	now := time.Now().Format(time.RFC3339)
+
+	if state == StateCompleted {
+		query := `
+			UPDATE jobs
+			SET state = ?, updated_at = ?
+			WHERE state = ?
+			  AND id IN (SELECT job_id FROM job_dependencies WHERE depends_on = ?)
+			  AND NOT EXISTS (
+				SELECT 1 FROM job_dependencies d JOIN jobs p ON p.id = d.depends_on
+				WHERE d.job_id = jobs.id AND p.state != ?)`
+
+		if _, err := tx.Exec(query, string(StatePending), now, string(StateBlocked), id, string(StateCompleted)); err != nil {
+			return fmt.Errorf("failed to unblock dependents of %s: %w", id, err)
+		}
+		return nil
+	}
+
+	if state != StateDead && state != StateCancelled {
+		return nil
+	}
+
+	// Walk down the graph; every job failed here fails its own dependents
+	failed := []string{id}
+	for len(failed) > 0 {
+		parent := failed[0]
+		failed = failed[1:]
+
+		query := `
+			SELECT j.id, j.dependency_policy
+			FROM jobs j JOIN job_dependencies d ON d.job_id = j.id
+			WHERE d.depends_on = ? AND j.state = ?`
+
+		rows, err := tx.Query(query, parent, string(StateBlocked))
+		if err != nil {
+			return fmt.Errorf("failed to find dependents of %s: %w", parent, err)
+		}
+
+		var children []Dependency
+		for rows.Next() {
+			var childID, policy string
+			if err := rows.Scan(&childID, &policy); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan dependent: %w", err)
+			}
+			children = append(children, Dependency{ID: childID, State: DependencyPolicy(policy).failedState()})
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to find dependents of %s: %w", parent, err)
+		}
+		rows.Close()
+
+		for _, child := range children {
+			if _, err := tx.Exec(`UPDATE jobs SET state = ?, updated_at = ? WHERE id = ? AND state = ?`,
+				string(child.State), now, child.ID, string(StateBlocked)); err != nil {
+				return fmt.Errorf("failed to update dependent %s: %w", child.ID, err)
+			}
+			if child.State == StateDead {
+				if err := MarkDead(tx, child.ID, fmt.Sprintf("dependency %s did not complete", parent), nil, ""); err != nil {
+					return err
+				}
+			}
+			failed = append(failed, child.ID)
+		}
+	}
+
+	return nil
+}
+
+// ListUpstream returns the jobs that a job depends on
+func ListUpstream(id string) ([]*Dependency, error) {
+	query := `
+		SELECT p.id, p.state
+		FROM job_dependencies d JOIN jobs p ON p.id = d.depends_on
+		WHERE d.job_id = ?
+		ORDER BY p.created_at ASC`
+
+	return listDependencies(query, id)
+}
+
+// ListDownstream returns the jobs that depend on a job
+func ListDownstream(id string) ([]*Dependency, error) {
+	query := `
+		SELECT c.id, c.state
+		FROM job_dependencies d JOIN jobs c ON c.id = d.job_id
+		WHERE d.depends_on = ?
+		ORDER BY c.created_at ASC`
+
+	return listDependencies(query, id)
+}
+
+// listDependencies runs a query selecting (id, state) pairs
+func listDependencies(query, id string) ([]*Dependency, error) {
+	rows, err := db.GetDB().Query(query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dependencies: %w", err)
+	}
+	defer rows.Close()
+
+	deps := []*Dependency{}
+	for rows.Next() {
+		var d Dependency
+		if err := rows.Scan(&d.ID, &d.State); err != nil {
+			return nil, fmt.Errorf("failed to scan dependency: %w", err)
+		}
+		deps = append(deps, &d)
+	}
+
+	return deps, nil
+}
@@ -0,0 +1,408 @@
+package job
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"queuectl/internal/db"
+)
+
+// stderrTailBytes is how much of the last attempt's stderr is kept on a dead job
+const stderrTailBytes = 4096
+
+// MarkDead records why a job was moved to the DLQ and when
+// exitCode is nil when the command did not exit on its own (or never ran).
+func MarkDead(tx *sql.Tx, id, reason string, exitCode *int, stderr string) error {
+	var code interface{}
+	if exitCode != nil {
+		code = *exitCode
+	}
+
+	_, err := tx.Exec(
+		`UPDATE jobs SET last_error = ?, last_exit_code = ?, stderr_tail = ?, dead_at = ? WHERE id = ?`,
+		reason,
+		code,
+		tail(stderr, stderrTailBytes),
+		time.Now().Format(time.RFC3339),
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record failure reason: %w", err)
+	}
+	return nil
+}
+
+// tail returns roughly the last n bytes of s, starting at a line boundary when possible
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = s[len(s)-n:]
+	if i := strings.IndexByte(s, '\n'); i >= 0 && i < len(s)-1 {
+		s = s[i+1:]
+	}
+	return s
+}
+
+// retryDeadSet is the SET clause that moves a dead job back into the queue:
+// blocked if any job it depends on has not completed, pending otherwise.
+// It takes the arguments returned by retryDeadArgs.
+const retryDeadSet = `
+	state = CASE WHEN EXISTS (
+			SELECT 1 FROM job_dependencies d JOIN jobs p ON p.id = d.depends_on
+			WHERE d.job_id = jobs.id AND p.state != ?)
+		THEN ? ELSE ? END,
+	attempts = 0, next_retry_at = NULL, updated_at = ?,
+	last_error = NULL, last_exit_code = NULL, stderr_tail = NULL, dead_at = NULL`
+
+func retryDeadArgs() []interface{} {
+	return []interface{}{
+		string(StateCompleted),
+		string(StateBlocked),
+		string(StatePending),
+		time.Now().Format(time.RFC3339),
+	}
+}
+
+// ErrFailedDependency is returned when retrying a dead job that depends on a dead or
+// cancelled job: it would stay blocked for good, since that job never completes
+var ErrFailedDependency = errors.New("job depends on a job that will not complete")
+
+// failedParent returns a dead or cancelled job that id depends on and that is not
+// being retried along with it, or nil if there is none
+func failedParent(tx *sql.Tx, id string, retrying map[string]bool) (*Dependency, error) {
+	query := `
+		SELECT p.id, p.state
+		FROM job_dependencies d JOIN jobs p ON p.id = d.depends_on
+		WHERE d.job_id = ? AND p.state IN (?, ?)
+		ORDER BY p.created_at ASC`
+
+	rows, err := tx.Query(query, id, string(StateDead), string(StateCancelled))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up dependencies of %s: %w", id, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d Dependency
+		if err := rows.Scan(&d.ID, &d.State); err != nil {
+			return nil, fmt.Errorf("failed to scan dependency: %w", err)
+		}
+		if d.State == StateDead && retrying[d.ID] {
+			continue
+		}
+		return &d, nil
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to look up dependencies of %s: %w", id, err)
+	}
+	return nil, nil
+}
+
+// retryOne moves a single dead job back into the queue inside tx, after extra SET
+// assignments taking args; it refuses a job whose dependencies can no longer complete
+func retryOne(tx *sql.Tx, id, set string, args ...interface{}) error {
+	query := `UPDATE jobs SET ` + set + retryDeadSet + ` WHERE id = ? AND state = ?`
+	args = append(args, retryDeadArgs()...)
+	args = append(args, id, string(StateDead))
+
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		if isUniqueViolation(err, "jobs.dedup_key") {
+			return fmt.Errorf("job %s: its dedup_key is used by another active job: %w", id, ErrDuplicateKey)
+		}
+		return fmt.Errorf("failed to retry dead job: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("job not found or not in dead state: %s", id)
+	}
+
+	parent, err := failedParent(tx, id, nil)
+	if err != nil {
+		return err
+	}
+	if parent != nil {
+		return fmt.Errorf("%w: %s depends on %s, which is %s", ErrFailedDependency, id, parent.ID, parent.State)
+	}
+	return nil
+}
+
+// DeadFilter selects jobs in the DLQ; empty fields match every dead job
+type DeadFilter struct {
+	// ID, if set, selects that single job
+	ID    string
+	Queue string
+	Tag   string
+	// Error is matched case-insensitively against last_error; '*' matches any run of characters
+	Error string
+	// Since and Until bound the time the job was dead-lettered
+	Since time.Time
+	Until time.Time
+}
+
+// ParseDeadFilter builds a DeadFilter from key=value selectors:
+// queue=NAME, tag=NAME, error=PATTERN, since=TIME and until=TIME,
+// where TIME is RFC3339 or a duration such as "2h" meaning that long ago
+func ParseDeadFilter(selectors []string) (DeadFilter, error) {
+	var f DeadFilter
+	for _, selector := range selectors {
+		key, value, ok := strings.Cut(selector, "=")
+		if !ok || value == "" {
+			return f, fmt.Errorf("invalid filter '%s' (use key=value)", selector)
+		}
+
+		switch key {
+		case "queue":
+			f.Queue = value
+		case "tag":
+			f.Tag = value
+		case "error":
+			f.Error = value
+		case "since", "until":
+			t, err := parseFilterTime(value)
+			if err != nil {
+				return f, fmt.Errorf("invalid %s '%s': %w", key, value, err)
+			}
+			if key == "since" {
+				f.Since = t
+			} else {
+				f.Until = t
+			}
+		default:
+			return f, fmt.Errorf("unknown filter '%s' (use queue, tag, error, since or until)", key)
+		}
+	}
+	return f, nil
+}
+
+// parseFilterTime parses an RFC3339 time or a duration counted back from now
+func parseFilterTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("must be an RFC3339 time or a duration like \"2h\"")
+	}
+	return time.Now().Add(-d), nil
+}
+
+// where returns the WHERE clause selecting the jobs matched by f, and its arguments
+func (f DeadFilter) where() (string, []interface{}) {
+	clauses := []string{"state = ?"}
+	args := []interface{}{string(StateDead)}
+
+	if f.ID != "" {
+		clauses = append(clauses, "id = ?")
+		args = append(args, f.ID)
+	}
+	if f.Queue != "" {
+		clauses = append(clauses, "queue = ?")
+		args = append(args, f.Queue)
+	}
+	if f.Tag != "" {
+		clauses = append(clauses, "EXISTS (SELECT 1 FROM json_each(jobs.tags) WHERE value = ?)")
+		args = append(args, f.Tag)
+	}
+	if f.Error != "" {
+		pattern := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`, "*", "%").Replace(f.Error)
+		clauses = append(clauses, `last_error LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+pattern+"%")
+	}
+	// Jobs dead-lettered before dead_at existed fall back to their last update
+	if !f.Since.IsZero() {
+		clauses = append(clauses, "COALESCE(dead_at, updated_at) >= ?")
+		args = append(args, f.Since.Local().Format(time.RFC3339))
+	}
+	if !f.Until.IsZero() {
+		clauses = append(clauses, "COALESCE(dead_at, updated_at) <= ?")
+		args = append(args, f.Until.Local().Format(time.RFC3339))
+	}
+
+	return strings.Join(clauses, " AND "), args
+}
+
+// ListDead returns the dead jobs matched by f, most recently dead-lettered first
+func ListDead(f DeadFilter) ([]*Job, error) {
+	where, args := f.where()
+	query := `SELECT ` + jobColumns + ` FROM jobs WHERE ` + where + ` ORDER BY COALESCE(dead_at, updated_at) DESC`
+
+	rows, err := db.GetDB().Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		jobs = append(jobs, j)
+	}
+
+	return jobs, nil
+}
+
+// RetryDead moves every dead job matched by f back into the queue in one transaction
+// and returns how many were moved. Jobs depending on a dead job that is not retried
+// with them, or on a cancelled job, are left in the DLQ and returned as skipped.
+func RetryDead(f DeadFilter) (int, []string, error) {
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	ids, err := deadIDs(tx, f)
+	if err != nil {
+		return 0, nil, err
+	}
+	retrying := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		retrying[id] = true
+	}
+
+	// Skipping a job can strand the jobs depending on it, so repeat until nothing changes
+	for changed := true; changed; {
+		changed = false
+		for _, id := range ids {
+			if !retrying[id] {
+				continue
+			}
+			parent, err := failedParent(tx, id, retrying)
+			if err != nil {
+				return 0, nil, err
+			}
+			if parent != nil {
+				retrying[id] = false
+				changed = true
+			}
+		}
+	}
+
+	retried := 0
+	var skipped []string
+	for _, id := range ids {
+		if !retrying[id] {
+			skipped = append(skipped, id)
+			continue
+		}
+		if _, err := tx.Exec(`UPDATE jobs SET `+retryDeadSet+` WHERE id = ? AND state = ?`,
+			append(retryDeadArgs(), id, string(StateDead))...); err != nil {
+			if isUniqueViolation(err, "jobs.dedup_key") {
+				return 0, nil, fmt.Errorf("job %s: its dedup_key is used by another active job: %w", id, ErrDuplicateKey)
+			}
+			return 0, nil, fmt.Errorf("failed to retry dead job %s: %w", id, err)
+		}
+		retried++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return retried, skipped, nil
+}
+
+// deadIDs returns the ids of the dead jobs matched by f
+func deadIDs(tx *sql.Tx, f DeadFilter) ([]string, error) {
+	where, args := f.where()
+
+	rows, err := tx.Query(`SELECT id FROM jobs WHERE `+where+` ORDER BY created_at ASC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan dead job: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list dead jobs: %w", err)
+	}
+	return ids, nil
+}
+
+// PurgeDead deletes every dead job matched by f, with its logs and execution history,
+// in one transaction and returns how many were deleted. Blocked jobs depending on a
+// purged job can no longer run, so they move to the state their dependency_policy asks for.
+func PurgeDead(f DeadFilter) (int, error) {
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Collect the ids first: resolving dependents may dead-letter more jobs,
+	// which must not be purged along with them
+	ids, err := deadIDs(tx, f)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		if err := ResolveDependents(tx, id, StateDead); err != nil {
+			return 0, err
+		}
+		// Logs, runs and the job's own dependencies go with it (ON DELETE CASCADE);
+		// edges from other jobs pointing at it are removed here
+		if _, err := tx.Exec(`DELETE FROM job_dependencies WHERE depends_on = ?`, id); err != nil {
+			return 0, fmt.Errorf("failed to remove dependencies on %s: %w", id, err)
+		}
+		if _, err := tx.Exec(`DELETE FROM jobs WHERE id = ?`, id); err != nil {
+			return 0, fmt.Errorf("failed to purge dead job %s: %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(ids), nil
+}
+
+// Requeue saves edits made to a dead job (command or args, queue, max_retries,
+// priority and timeout) and moves it back into the queue like RetryDeadJob
+func Requeue(j *Job) error {
+	if err := j.Validate(); err != nil {
+		return err
+	}
+
+	var args interface{}
+	if len(j.Args) > 0 {
+		data, err := json.Marshal(j.Args)
+		if err != nil {
+			return fmt.Errorf("failed to encode args: %w", err)
+		}
+		args = string(data)
+	}
+
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	set := `command = ?, args = ?, queue = ?, max_retries = ?, priority = ?, timeout_ms = ?,`
+	if err := retryOne(tx, j.ID, set, j.Command, args, j.Queue, j.MaxRetries, j.Priority, time.Duration(j.Timeout).Milliseconds()); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
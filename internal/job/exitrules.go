@@ -0,0 +1,104 @@
+package job
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// ExitAction is what an exit rule does with an attempt it matches
+type ExitAction string
+
+const (
+	// ExitSuccess treats the attempt as successful
+	ExitSuccess ExitAction = "success"
+	// ExitFail treats the attempt as an ordinary failure that counts towards max_retries
+	ExitFail ExitAction = "fail"
+	// ExitTempfail retries the job without counting the attempt
+	ExitTempfail ExitAction = "tempfail"
+	// ExitDead moves the job straight to the DLQ
+	ExitDead ExitAction = "dead"
+)
+
+// ExitRule maps how a command exited to an ExitAction
+// A rule matches when the exit code is one of ExitCodes (if set) and stderr
+// matches the Stderr regular expression (if set).
+type ExitRule struct {
+	ExitCodes []int      `json:"exit_codes,omitempty"`
+	Stderr    string     `json:"stderr,omitempty"`
+	Action    ExitAction `json:"action"`
+}
+
+// Validate checks the action and pattern of a rule
+func (r *ExitRule) Validate() error {
+	switch r.Action {
+	case ExitSuccess, ExitFail, ExitTempfail, ExitDead:
+	default:
+		return fmt.Errorf("invalid exit rule action '%s' (must be success, fail, tempfail or dead)", r.Action)
+	}
+	if len(r.ExitCodes) == 0 && r.Stderr == "" {
+		return fmt.Errorf("exit rule needs exit_codes or a stderr pattern")
+	}
+	if r.Stderr != "" {
+		if _, err := regexp.Compile(r.Stderr); err != nil {
+			return fmt.Errorf("invalid exit rule stderr pattern: %w", err)
+		}
+	}
+	return nil
+}
+
+// matches reports whether the rule applies to an exit code and stderr
+func (r *ExitRule) matches(exitCode int, stderr string) bool {
+	if len(r.ExitCodes) > 0 {
+		found := false
+		for _, code := range r.ExitCodes {
+			if code == exitCode {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.Stderr != "" {
+		re, err := regexp.Compile(r.Stderr)
+		if err != nil || !re.MatchString(stderr) {
+			return false
+		}
+	}
+	return true
+}
+
+// Classify decides what to do with an attempt: the first matching rule wins,
+// otherwise a successful attempt is ExitSuccess and a failed one ExitFail.
+// Rules only look at commands that exited on their own; timeouts, cancellations
+// and commands that could not be started are always ExitFail. A matching rule
+// that changes the outcome updates result.Success and result.Error accordingly.
+func Classify(rules []ExitRule, result *ExecuteResult) ExitAction {
+	action := ExitFail
+	if result.Success {
+		action = ExitSuccess
+	}
+	if result.TimedOut || result.Cancelled || result.ExitCode < 0 {
+		return action
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.matches(result.ExitCode, result.Stderr) {
+			continue
+		}
+
+		switch {
+		case rule.Action == ExitSuccess && !result.Success:
+			result.Success = true
+			result.Error = nil
+		case rule.Action != ExitSuccess && result.Success:
+			result.Success = false
+			result.Error = fmt.Errorf("exit code %d matched an exit rule marking it as %s", result.ExitCode, rule.Action)
+		}
+		return rule.Action
+	}
+	return action
+}
+
@@ -0,0 +1,64 @@
+package job
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// crockford is the ULID alphabet: Crockford's base32 without I, L, O and U
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var (
+	idMu       sync.Mutex
+	lastIDTime int64
+	lastIDRand [10]byte
+)
+
+// NewID returns a new ULID: 26 characters that sort by creation time
+// IDs generated within the same millisecond by this process stay in order.
+func NewID() string {
+	idMu.Lock()
+	defer idMu.Unlock()
+
+	ms := time.Now().UnixMilli()
+	if ms <= lastIDTime {
+		// Same millisecond (or the clock went back): increment the random part
+		ms = lastIDTime
+		for i := len(lastIDRand) - 1; i >= 0; i-- {
+			lastIDRand[i]++
+			if lastIDRand[i] != 0 {
+				break
+			}
+		}
+	} else {
+		if _, err := rand.Read(lastIDRand[:]); err != nil {
+			// crypto/rand does not fail on supported platforms; fall back to the clock
+			binary.BigEndian.PutUint64(lastIDRand[2:], uint64(time.Now().UnixNano()))
+		}
+		lastIDTime = ms
+	}
+
+	// 48-bit timestamp followed by 80 random bits
+	var raw [16]byte
+	raw[0] = byte(ms >> 40)
+	raw[1] = byte(ms >> 32)
+	raw[2] = byte(ms >> 24)
+	raw[3] = byte(ms >> 16)
+	raw[4] = byte(ms >> 8)
+	raw[5] = byte(ms)
+	copy(raw[6:], lastIDRand[:])
+
+	// Encode 128 bits as 26 base32 characters, 5 bits at a time from the top
+	hi := binary.BigEndian.Uint64(raw[:8])
+	lo := binary.BigEndian.Uint64(raw[8:])
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
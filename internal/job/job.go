@@ -1,83 +1,288 @@
-package job
-
-import (
-	"encoding/json"
-	"fmt"
-	"time"
-)
-
-// State represents the state of a job
-type State string
-
-const (
-	StatePending   State = "pending"
-	StateProcessing State = "processing"
-	StateCompleted  State = "completed"
-	StateFailed     State = "failed"
-	StateDead       State = "dead"
-)
-
-// Job represents a background job
-type Job struct {
-	ID          string    `json:"id"`
-	Command     string    `json:"command"`
-	State       State     `json:"state"`
-	Attempts    int       `json:"attempts"`
-	MaxRetries  int       `json:"max_retries"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	NextRetryAt *time.Time `json:"next_retry_at,omitempty"`
-}
-
-// Validate validates a job
-func (j *Job) Validate() error {
-	if j.ID == "" {
-		return fmt.Errorf("job ID is required")
-	}
-	if j.Command == "" {
-		return fmt.Errorf("job command is required")
-	}
-	if j.MaxRetries < 0 {
-		return fmt.Errorf("max_retries must be non-negative")
-	}
-	return nil
-}
-
-// FromJSON creates a Job from JSON string
-func FromJSON(jsonStr string) (*Job, error) {
-	var j Job
-	if err := json.Unmarshal([]byte(jsonStr), &j); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %w", err)
-	}
-
-	// Set defaults
-	if j.State == "" {
-		j.State = StatePending
-	}
-	if j.MaxRetries == 0 {
-		j.MaxRetries = 3
-	}
-	now := time.Now()
-	if j.CreatedAt.IsZero() {
-		j.CreatedAt = now
-	}
-	if j.UpdatedAt.IsZero() {
-		j.UpdatedAt = now
-	}
-
-	if err := j.Validate(); err != nil {
-		return nil, err
-	}
-
-	return &j, nil
-}
-
-// ToJSON converts a Job to JSON string
-func (j *Job) ToJSON() (string, error) {
-	data, err := json.Marshal(j)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal job: %w", err)
-	}
-	return string(data), nil
-}
-
+package job
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// State represents the state of a job
+type State string
+
+const (
+	StatePending    State = "pending"
+	StateBlocked    State = "blocked" // waiting for the jobs it depends on
+	StateProcessing State = "processing"
+	StateCompleted  State = "completed"
+	StateFailed     State = "failed"
+	StateDead       State = "dead"
+	StateCancelled  State = "cancelled"
+)
+
+// DefaultQueue is the queue jobs go to when none is given
+const DefaultQueue = "default"
+
+// queueNamePattern restricts queue names so they can be listed in --queues a:2,b:1
+var queueNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// AllStates lists every job state in lifecycle order
+var AllStates = []State{
+	StatePending,
+	StateBlocked,
+	StateProcessing,
+	StateCompleted,
+	StateFailed,
+	StateDead,
+	StateCancelled,
+}
+
+// Valid reports whether s is one of AllStates
+func (s State) Valid() bool {
+	for _, state := range AllStates {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// Job represents a background job
+type Job struct {
+	ID          string            `json:"id"`
+	Queue       string            `json:"queue"`
+	Tags        []string          `json:"tags,omitempty"`    // free-form labels, e.g. for selecting DLQ jobs
+	Command     string            `json:"command,omitempty"` // run through sh -c (cmd.exe /c on Windows)
+	Args        []string          `json:"args,omitempty"`    // run directly, without a shell
+	Env         map[string]string `json:"env,omitempty"`     // added to the worker's environment
+	Cwd         string            `json:"cwd,omitempty"`     // working directory, default the worker's
+	Payload     json.RawMessage   `json:"payload,omitempty"` // arbitrary JSON handed to the command
+	PayloadMode PayloadMode       `json:"payload_mode,omitempty"`
+	Result      json.RawMessage   `json:"result,omitempty"` // written by the command, see QUEUECTL_RESULT_FILE
+	State       State             `json:"state"`
+	Attempts    int               `json:"attempts"`
+	MaxRetries  int               `json:"max_retries"`
+	Priority    int               `json:"priority"` // higher runs first
+	Timeout     Duration          `json:"timeout,omitempty"`
+	Retry       *RetryPolicy      `json:"retry,omitempty"`      // overrides the configured retry policy
+	ExitRules   []ExitRule        `json:"exit_rules,omitempty"` // checked before the configured exit rules
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	NextRetryAt *time.Time        `json:"next_retry_at,omitempty"`
+
+	// RunAt defers the job until the given time. Delay is an enqueue-time
+	// shorthand for "now + delay" and is turned into RunAt by FromJSON.
+	RunAt *time.Time `json:"run_at,omitempty"`
+	Delay Duration   `json:"delay,omitempty"`
+
+	// DependsOn lists jobs that must complete before this one runs.
+	// DependencyPolicy decides what happens to the job if one of them ends up dead.
+	DependsOn        []string         `json:"depends_on,omitempty"`
+	DependencyPolicy DependencyPolicy `json:"dependency_policy,omitempty"`
+
+	// DedupKey makes enqueueing idempotent: a job whose key matches an active job,
+	// or one completed within DedupWindow, is handled according to DedupMode
+	// instead of being queued twice. DedupWindow and DedupMode are enqueue-time only.
+	DedupKey    string    `json:"dedup_key,omitempty"`
+	DedupWindow Duration  `json:"dedup_window,omitempty"`
+	DedupMode   DedupMode `json:"dedup_mode,omitempty"`
+
+	// Set while a worker holds the job in processing state
+	LeaseOwner     string     `json:"lease_owner,omitempty"`
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty"`
+	// CancelRequested asks the worker running the job to stop it
+	CancelRequested bool `json:"cancel_requested,omitempty"`
+
+	// Why and when the job was moved to the DLQ
+	LastError    string     `json:"last_error,omitempty"`
+	LastExitCode *int       `json:"last_exit_code,omitempty"`
+	StderrTail   string     `json:"stderr_tail,omitempty"`
+	DeadAt       *time.Time `json:"dead_at,omitempty"`
+}
+
+// Duration is a time.Duration that reads from JSON as either a number of
+// seconds or a Go duration string such as "90s" or "5m", and writes as the latter
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		*d = Duration(seconds * float64(time.Second))
+		return nil
+	}
+
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return fmt.Errorf("duration must be a number of seconds or a string like \"30s\"")
+	}
+	parsed, err := time.ParseDuration(str)
+	if err != nil {
+		return fmt.Errorf("invalid duration '%s': %w", str, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Validate validates a job
+func (j *Job) Validate() error {
+	if j.ID == "" {
+		return fmt.Errorf("job ID is required")
+	}
+	if !queueNamePattern.MatchString(j.Queue) {
+		return fmt.Errorf("invalid queue name '%s' (use letters, digits, '.', '_' or '-')", j.Queue)
+	}
+	for _, tag := range j.Tags {
+		if strings.TrimSpace(tag) == "" {
+			return fmt.Errorf("tags must not be empty")
+		}
+	}
+	if j.Command == "" && len(j.Args) == 0 {
+		return fmt.Errorf("job command or args is required")
+	}
+	if j.Command != "" && len(j.Args) > 0 {
+		return fmt.Errorf("set either command or args, not both")
+	}
+	if len(j.Args) > 0 && j.Args[0] == "" {
+		return fmt.Errorf("args[0] must name the program to run")
+	}
+	switch j.PayloadMode {
+	case "", PayloadStdin, PayloadFile:
+	default:
+		return fmt.Errorf("invalid payload_mode '%s' (must be stdin or file)", j.PayloadMode)
+	}
+	for name := range j.Env {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			return fmt.Errorf("invalid env variable name '%s'", name)
+		}
+	}
+	if j.MaxRetries < 0 {
+		return fmt.Errorf("max_retries must be non-negative")
+	}
+	if j.Timeout < 0 {
+		return fmt.Errorf("timeout must be non-negative")
+	}
+	if j.Retry != nil {
+		if err := j.Retry.Validate(); err != nil {
+			return err
+		}
+	}
+	for i := range j.ExitRules {
+		if err := j.ExitRules[i].Validate(); err != nil {
+			return err
+		}
+	}
+	for _, parent := range j.DependsOn {
+		if parent == "" {
+			return fmt.Errorf("depends_on must not contain empty job IDs")
+		}
+		if parent == j.ID {
+			return fmt.Errorf("job cannot depend on itself")
+		}
+	}
+	if j.DedupKey == "" && (j.DedupWindow != 0 || j.DedupMode != "") {
+		return fmt.Errorf("dedup_window and dedup_mode require a dedup_key")
+	}
+	if j.DedupWindow < 0 {
+		return fmt.Errorf("dedup_window must be non-negative")
+	}
+	switch j.DedupMode {
+	case "", DedupExisting, DedupReject, DedupReplace:
+	default:
+		return fmt.Errorf("invalid dedup_mode '%s' (must be existing, reject or replace)", j.DedupMode)
+	}
+	switch j.DependencyPolicy {
+	case "", DependencyCancel, DependencyDead:
+	default:
+		return fmt.Errorf("invalid dependency_policy '%s' (must be cancel or dead)", j.DependencyPolicy)
+	}
+	return nil
+}
+
+// FromJSON creates a Job from JSON string
+func FromJSON(jsonStr string) (*Job, error) {
+	var j Job
+	if err := json.Unmarshal([]byte(jsonStr), &j); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	now := time.Now()
+
+	// Resolve delay into an absolute run_at
+	if j.Delay != 0 {
+		if j.RunAt != nil {
+			return nil, fmt.Errorf("set either run_at or delay, not both")
+		}
+		if j.Delay < 0 {
+			return nil, fmt.Errorf("delay must be non-negative")
+		}
+		runAt := now.Add(time.Duration(j.Delay))
+		j.RunAt = &runAt
+		j.Delay = 0
+	}
+
+	// An explicit null payload is no payload
+	if string(j.Payload) == "null" {
+		j.Payload = nil
+	}
+
+	// Set defaults
+	if j.ID == "" {
+		j.ID = NewID()
+	}
+	if j.State == "" {
+		j.State = StatePending
+	}
+	if j.MaxRetries == 0 {
+		j.MaxRetries = 3
+	}
+	if j.Queue == "" {
+		j.Queue = DefaultQueue
+	}
+	if len(j.DependsOn) > 0 && j.DependencyPolicy == "" {
+		j.DependencyPolicy = DependencyCancel
+	}
+	if j.DedupKey != "" && j.DedupMode == "" {
+		j.DedupMode = DedupExisting
+	}
+	if j.CreatedAt.IsZero() {
+		j.CreatedAt = now
+	}
+	if j.UpdatedAt.IsZero() {
+		j.UpdatedAt = now
+	}
+
+	if err := j.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &j, nil
+}
+
+// EligibleAt returns when the job may next be claimed by a worker
+func (j *Job) EligibleAt() time.Time {
+	switch {
+	case j.State == StateFailed && j.NextRetryAt != nil:
+		return *j.NextRetryAt
+	case j.RunAt != nil:
+		return *j.RunAt
+	default:
+		return j.CreatedAt
+	}
+}
+
+// ToJSON converts a Job to JSON string
+func (j *Job) ToJSON() (string, error) {
+	data, err := json.Marshal(j)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal job: %w", err)
+	}
+	return string(data), nil
+}
+
@@ -0,0 +1,212 @@
+package job
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"queuectl/internal/db"
+)
+
+// ErrLeaseLost is returned when a worker no longer holds the lease on a job,
+// usually because the lease expired and the reaper handed the job to someone else
+var ErrLeaseLost = errors.New("lease lost")
+
+// ReapedJob describes a job recovered from an expired lease
+type ReapedJob struct {
+	ID       string `json:"id"`
+	Owner    string `json:"owner,omitempty"`
+	State    State  `json:"state"`
+	Attempts int    `json:"attempts"`
+}
+
+// RenewLease extends the lease a worker holds on a processing job
+func RenewLease(id, owner string, lease time.Duration) error {
+	query := `
+		UPDATE jobs
+		SET lease_expires_at = ?
+		WHERE id = ? AND state = ? AND lease_owner = ?`
+
+	result, err := db.GetDB().Exec(
+		query,
+		time.Now().Add(lease).Format(time.RFC3339),
+		id,
+		string(StateProcessing),
+		owner,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to renew lease: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// UpdateLeasedState moves a processing job to its next state and releases the lease
+// Returns ErrLeaseLost if owner no longer holds the lease, leaving the job untouched
+func UpdateLeasedState(tx *sql.Tx, id, owner string, state State, attempts int, nextRetryAt *time.Time) error {
+	query := `
+		UPDATE jobs
+		SET state = ?, attempts = ?, updated_at = ?, next_retry_at = ?, lease_owner = NULL, lease_expires_at = NULL, cancel_requested = 0
+		WHERE id = ? AND state = ? AND lease_owner = ?`
+
+	var nextRetryAtStr interface{}
+	if nextRetryAt != nil {
+		nextRetryAtStr = nextRetryAt.Format(time.RFC3339)
+	}
+
+	result, err := tx.Exec(
+		query,
+		string(state),
+		attempts,
+		time.Now().Format(time.RFC3339),
+		nextRetryAtStr,
+		id,
+		string(StateProcessing),
+		owner,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update job state: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// ReapExpiredLeases returns processing jobs whose lease has expired to the queue
+// The interrupted attempt is counted: the job is scheduled for retry, or moved
+// to the DLQ if it has no retries left, or cancelled if that was requested.
+// Processing jobs without a lease (claimed by an older version) are treated as expired.
+// Retries are scheduled with retryPolicy, overridden by each job's own policy.
+func ReapExpiredLeases(retryPolicy RetryPolicy) ([]ReapedJob, error) {
+	now := time.Now()
+	nowStr := now.Format(time.RFC3339)
+
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT id, COALESCE(lease_owner, ''), attempts, max_retries, cancel_requested, retry_policy
+		FROM jobs
+		WHERE state = ? AND (lease_expires_at IS NULL OR lease_expires_at < ?)`
+
+	rows, err := tx.Query(query, string(StateProcessing), nowStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find expired leases: %w", err)
+	}
+
+	var reaped []ReapedJob
+	var maxRetries []int
+	var cancelled []bool
+	var policies []RetryPolicy
+	for rows.Next() {
+		var r ReapedJob
+		var max int
+		var cancel bool
+		var policyStr sql.NullString
+		if err := rows.Scan(&r.ID, &r.Owner, &r.Attempts, &max, &cancel, &policyStr); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan expired lease: %w", err)
+		}
+
+		policy := retryPolicy
+		if policyStr.Valid {
+			var override RetryPolicy
+			if err := json.Unmarshal([]byte(policyStr.String), &override); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to parse retry policy of job %s: %w", r.ID, err)
+			}
+			policy = policy.Override(&override)
+		}
+
+		reaped = append(reaped, r)
+		maxRetries = append(maxRetries, max)
+		cancelled = append(cancelled, cancel)
+		policies = append(policies, policy)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, fmt.Errorf("failed to find expired leases: %w", err)
+	}
+	rows.Close()
+
+	var recovered []ReapedJob
+	for i := range reaped {
+		r := &reaped[i]
+		r.Attempts++
+
+		var nextRetryAt interface{}
+		if cancelled[i] {
+			r.State = StateCancelled
+		} else if r.Attempts > maxRetries[i] {
+			r.State = StateDead
+		} else {
+			r.State = StateFailed
+			nextRetryAt = policies[i].NextRetry(r.Attempts).Format(time.RFC3339)
+		}
+
+		// Re-check the lease so a heartbeat that landed in the meantime wins
+		updateQuery := `
+			UPDATE jobs
+			SET state = ?, attempts = ?, updated_at = ?, next_retry_at = ?, lease_owner = NULL, lease_expires_at = NULL, cancel_requested = 0
+			WHERE id = ? AND state = ? AND (lease_expires_at IS NULL OR lease_expires_at < ?)`
+
+		result, err := tx.Exec(
+			updateQuery,
+			string(r.State),
+			r.Attempts,
+			nowStr,
+			nextRetryAt,
+			r.ID,
+			string(StateProcessing),
+			nowStr,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to recover job %s: %w", r.ID, err)
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			continue
+		}
+
+		if err := abandonRuns(tx, r.ID, now); err != nil {
+			return nil, err
+		}
+		if r.State == StateDead {
+			if err := MarkDead(tx, r.ID, "worker lease expired", nil, ""); err != nil {
+				return nil, err
+			}
+		}
+		if err := ResolveDependents(tx, r.ID, r.State); err != nil {
+			return nil, err
+		}
+		recovered = append(recovered, *r)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return recovered, nil
+}
+
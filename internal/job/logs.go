@@ -0,0 +1,92 @@
+package job
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"queuectl/internal/db"
+)
+
+// Log holds the captured output of a single job attempt
+type Log struct {
+	JobID     string    `json:"job_id"`
+	Attempt   int       `json:"attempt"`
+	Stdout    string    `json:"stdout"`
+	Stderr    string    `json:"stderr"`
+	ExitCode  int       `json:"exit_code"`
+	Signal    string    `json:"signal,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SaveLog stores the output of an attempt
+func SaveLog(tx *sql.Tx, jobID string, attempt int, result ExecuteResult) error {
+	query := `
+		INSERT INTO job_logs (job_id, attempt, stdout, stderr, exit_code, signal, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := tx.Exec(
+		query,
+		jobID,
+		attempt,
+		result.Stdout,
+		result.Stderr,
+		result.ExitCode,
+		result.Signal,
+		time.Now().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save job log: %w", err)
+	}
+	return nil
+}
+
+// GetLog retrieves the output of a job attempt
+// If attempt is 0, the most recent attempt is returned
+func GetLog(jobID string, attempt int) (*Log, error) {
+	query := `
+		SELECT job_id, attempt, stdout, stderr, exit_code, signal, created_at
+		FROM job_logs
+		WHERE job_id = ? AND (? = 0 OR attempt = ?)
+		ORDER BY attempt DESC
+		LIMIT 1`
+
+	var l Log
+	var createdAtStr string
+	err := db.GetDB().QueryRow(query, jobID, attempt, attempt).Scan(
+		&l.JobID,
+		&l.Attempt,
+		&l.Stdout,
+		&l.Stderr,
+		&l.ExitCode,
+		&l.Signal,
+		&createdAtStr,
+	)
+	if err == sql.ErrNoRows {
+		if attempt > 0 {
+			return nil, fmt.Errorf("no logs for attempt %d of job: %s", attempt, jobID)
+		}
+		return nil, fmt.Errorf("no logs recorded for job: %s", jobID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job log: %w", err)
+	}
+
+	l.CreatedAt, err = time.Parse(time.RFC3339, createdAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse created_at: %w", err)
+	}
+
+	return &l, nil
+}
+
+// CountLogs returns the number of attempts with recorded output for a job
+func CountLogs(jobID string) (int, error) {
+	var count int
+	err := db.GetDB().QueryRow(`SELECT COUNT(*) FROM job_logs WHERE job_id = ?`, jobID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count job logs: %w", err)
+	}
+	return count, nil
+}
+
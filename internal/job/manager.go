@@ -1,302 +1,576 @@
-package job
-
-import (
-	"database/sql"
-	"fmt"
-	"strings"
-	"time"
-
-	"queuectl/internal/db"
-)
-
-// Create inserts a new job into the database
-func Create(j *Job) error {
-	query := `
-		INSERT INTO jobs (id, command, state, attempts, max_retries, created_at, updated_at, next_retry_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
-
-	_, err := db.GetDB().Exec(
-		query,
-		j.ID,
-		j.Command,
-		string(j.State),
-		j.Attempts,
-		j.MaxRetries,
-		j.CreatedAt.Format(time.RFC3339),
-		j.UpdatedAt.Format(time.RFC3339),
-		nil,
-	)
-	if err != nil {
-		// Check if it's a UNIQUE constraint error (duplicate ID)
-		errStr := err.Error()
-		if strings.Contains(errStr, "UNIQUE constraint failed") && strings.Contains(errStr, "jobs.id") {
-			return fmt.Errorf("job with ID '%s' already exists", j.ID)
-		}
-		return fmt.Errorf("failed to create job: %w", err)
-	}
-	return nil
-}
-
-// GetByID retrieves a job by ID
-func GetByID(id string) (*Job, error) {
-	query := `
-		SELECT id, command, state, attempts, max_retries, created_at, updated_at, next_retry_at
-		FROM jobs
-		WHERE id = ?`
-
-	var j Job
-	var createdAtStr, updatedAtStr string
-	var nextRetryAtStr sql.NullString
-
-	err := db.GetDB().QueryRow(query, id).Scan(
-		&j.ID,
-		&j.Command,
-		&j.State,
-		&j.Attempts,
-		&j.MaxRetries,
-		&createdAtStr,
-		&updatedAtStr,
-		&nextRetryAtStr,
-	)
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("job not found: %s", id)
-	}
-	if err != nil {
-		return nil, fmt.Errorf("failed to get job: %w", err)
-	}
-
-	// Parse timestamps
-	j.CreatedAt, err = time.Parse(time.RFC3339, createdAtStr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse created_at: %w", err)
-	}
-
-	j.UpdatedAt, err = time.Parse(time.RFC3339, updatedAtStr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse updated_at: %w", err)
-	}
-
-	if nextRetryAtStr.Valid {
-		nextRetryAt, err := time.Parse(time.RFC3339, nextRetryAtStr.String)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse next_retry_at: %w", err)
-		}
-		j.NextRetryAt = &nextRetryAt
-	}
-
-	return &j, nil
-}
-
-// GetNextPendingJob retrieves the next pending job that's ready for processing
-// Also checks for failed jobs that are ready for retry (next_retry_at <= now)
-// Uses atomic SELECT and UPDATE to prevent duplicate processing
-func GetNextPendingJob(tx *sql.Tx) (*Job, error) {
-	now := time.Now().Format(time.RFC3339)
-	// SQLite doesn't support FOR UPDATE the same way, so we use a different approach:
-	// 1. Select the job
-	// 2. Immediately update its state to prevent other workers from picking it
-	query := `
-		SELECT id, command, state, attempts, max_retries, created_at, updated_at, next_retry_at
-		FROM jobs
-		WHERE (state = ? AND (next_retry_at IS NULL OR next_retry_at <= ?))
-		   OR (state = ? AND next_retry_at IS NOT NULL AND next_retry_at <= ?)
-		ORDER BY created_at ASC
-		LIMIT 1`
-
-	var j Job
-	var createdAtStr, updatedAtStr string
-	var nextRetryAtStr sql.NullString
-
-	err := tx.QueryRow(query, string(StatePending), now, string(StateFailed), now).Scan(
-		&j.ID,
-		&j.Command,
-		&j.State,
-		&j.Attempts,
-		&j.MaxRetries,
-		&createdAtStr,
-		&updatedAtStr,
-		&nextRetryAtStr,
-	)
-	if err == sql.ErrNoRows {
-		return nil, nil // No pending jobs
-	}
-	if err != nil {
-		return nil, fmt.Errorf("failed to get next pending job: %w", err)
-	}
-
-	// Parse timestamps
-	j.CreatedAt, err = time.Parse(time.RFC3339, createdAtStr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse created_at: %w", err)
-	}
-
-	j.UpdatedAt, err = time.Parse(time.RFC3339, updatedAtStr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse updated_at: %w", err)
-	}
-
-	if nextRetryAtStr.Valid {
-		nextRetryAt, err := time.Parse(time.RFC3339, nextRetryAtStr.String)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse next_retry_at: %w", err)
-		}
-		j.NextRetryAt = &nextRetryAt
-	}
-
-	// If it's a failed job ready for retry, update it to pending
-	if j.State == StateFailed {
-		if err := UpdateState(tx, j.ID, StatePending, j.Attempts, nil); err != nil {
-			return nil, fmt.Errorf("failed to update failed job to pending: %w", err)
-		}
-		j.State = StatePending
-		j.NextRetryAt = nil
-	}
-
-	return &j, nil
-}
-
-// UpdateState updates the job state and other fields
-func UpdateState(tx *sql.Tx, id string, state State, attempts int, nextRetryAt *time.Time) error {
-	now := time.Now()
-	query := `
-		UPDATE jobs
-		SET state = ?, attempts = ?, updated_at = ?, next_retry_at = ?
-		WHERE id = ?`
-
-	var nextRetryAtStr interface{}
-	if nextRetryAt != nil {
-		nextRetryAtStr = nextRetryAt.Format(time.RFC3339)
-	} else {
-		nextRetryAtStr = nil
-	}
-
-	_, err := tx.Exec(
-		query,
-		string(state),
-		attempts,
-		now.Format(time.RFC3339),
-		nextRetryAtStr,
-		id,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to update job state: %w", err)
-	}
-	return nil
-}
-
-// ListByState retrieves all jobs with a specific state
-func ListByState(state State) ([]*Job, error) {
-	query := `
-		SELECT id, command, state, attempts, max_retries, created_at, updated_at, next_retry_at
-		FROM jobs
-		WHERE state = ?
-		ORDER BY created_at DESC`
-
-	rows, err := db.GetDB().Query(query, string(state))
-	if err != nil {
-		return nil, fmt.Errorf("failed to list jobs: %w", err)
-	}
-	defer rows.Close()
-
-	var jobs []*Job
-	for rows.Next() {
-		var j Job
-		var createdAtStr, updatedAtStr string
-		var nextRetryAtStr sql.NullString
-
-		err := rows.Scan(
-			&j.ID,
-			&j.Command,
-			&j.State,
-			&j.Attempts,
-			&j.MaxRetries,
-			&createdAtStr,
-			&updatedAtStr,
-			&nextRetryAtStr,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan job: %w", err)
-		}
-
-		// Parse timestamps
-		j.CreatedAt, err = time.Parse(time.RFC3339, createdAtStr)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse created_at: %w", err)
-		}
-
-		j.UpdatedAt, err = time.Parse(time.RFC3339, updatedAtStr)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse updated_at: %w", err)
-		}
-
-		if nextRetryAtStr.Valid {
-			nextRetryAt, err := time.Parse(time.RFC3339, nextRetryAtStr.String)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse next_retry_at: %w", err)
-			}
-			j.NextRetryAt = &nextRetryAt
-		}
-
-		jobs = append(jobs, &j)
-	}
-
-	return jobs, nil
-}
-
-// GetStats returns counts of jobs by state
-func GetStats() (map[State]int, error) {
-	query := `
-		SELECT state, COUNT(*) as count
-		FROM jobs
-		GROUP BY state`
-
-	rows, err := db.GetDB().Query(query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get stats: %w", err)
-	}
-	defer rows.Close()
-
-	stats := make(map[State]int)
-	for rows.Next() {
-		var stateStr string
-		var count int
-		if err := rows.Scan(&stateStr, &count); err != nil {
-			return nil, fmt.Errorf("failed to scan stats: %w", err)
-		}
-		stats[State(stateStr)] = count
-	}
-
-	return stats, nil
-}
-
-// RetryDeadJob moves a dead job back to pending state
-func RetryDeadJob(id string) error {
-	query := `
-		UPDATE jobs
-		SET state = ?, attempts = 0, next_retry_at = NULL, updated_at = ?
-		WHERE id = ? AND state = ?`
-
-	now := time.Now()
-	result, err := db.GetDB().Exec(
-		query,
-		string(StatePending),
-		now.Format(time.RFC3339),
-		id,
-		string(StateDead),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to retry dead job: %w", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
-	}
-
-	if rowsAffected == 0 {
-		return fmt.Errorf("job not found or not in dead state: %s", id)
-	}
-
-	return nil
-}
-
+package job
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"queuectl/internal/db"
+)
+
+// ErrDuplicateID is returned by Create when a job with the same ID already exists
+var ErrDuplicateID = errors.New("duplicate job ID")
+
+// isUniqueViolation reports whether err is SQLite refusing a write that would
+// break a unique index on column, given as "table.column"
+func isUniqueViolation(err error, column string) bool {
+	errStr := err.Error()
+	return strings.Contains(errStr, "UNIQUE constraint failed") && strings.Contains(errStr, column)
+}
+
+// Create inserts a new job into the database
+// See CreateTx for how dedup keys are handled.
+func Create(j *Job) (Outcome, error) {
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	outcome, err := CreateTx(tx, j)
+	if err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return outcome, nil
+}
+
+// CreateTx inserts a new job within a transaction
+// A job with dependencies starts blocked until all of them have completed.
+// If j has a dedup_key that matches a queued job, the outcome depends on j.DedupMode:
+// with OutcomeExisting nothing is inserted and *j is overwritten with the existing job.
+func CreateTx(tx *sql.Tx, j *Job) (Outcome, error) {
+	outcome := OutcomeCreated
+	if j.DedupKey != "" {
+		var done bool
+		var err error
+		if outcome, done, err = dedup(tx, j); err != nil || done {
+			return outcome, err
+		}
+	}
+
+	if len(j.DependsOn) > 0 {
+		state, err := dependencyState(tx, j)
+		if err != nil {
+			return "", err
+		}
+		j.State = state
+	}
+
+	err := insertJob(tx, j)
+	if errors.Is(err, errActiveDedupKey) {
+		// The unique index caught a job with the same key that the lookup above
+		// did not see; settle it the same way as a duplicate found up front
+		var done bool
+		if outcome, done, err = dedup(tx, j); err != nil || done {
+			return outcome, err
+		}
+		err = insertJob(tx, j)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := insertDependencies(tx, j); err != nil {
+		return "", err
+	}
+	return outcome, nil
+}
+
+// insertJob inserts j into the jobs table
+func insertJob(tx *sql.Tx, j *Job) error {
+	query := `
+		INSERT INTO jobs (id, queue, tags, command, args, env, cwd, payload, payload_mode, state, attempts, max_retries, priority, timeout_ms, retry_policy, exit_rules, created_at, updated_at, next_retry_at, run_at, dependency_policy, dedup_key)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	var retryPolicy interface{}
+	if j.Retry != nil {
+		data, err := json.Marshal(j.Retry)
+		if err != nil {
+			return fmt.Errorf("failed to encode retry policy: %w", err)
+		}
+		retryPolicy = string(data)
+	}
+
+	var exitRules interface{}
+	if len(j.ExitRules) > 0 {
+		data, err := json.Marshal(j.ExitRules)
+		if err != nil {
+			return fmt.Errorf("failed to encode exit rules: %w", err)
+		}
+		exitRules = string(data)
+	}
+
+	var payload interface{}
+	if len(j.Payload) > 0 {
+		payload = string(j.Payload)
+	}
+
+	var tags interface{}
+	if len(j.Tags) > 0 {
+		data, err := json.Marshal(j.Tags)
+		if err != nil {
+			return fmt.Errorf("failed to encode tags: %w", err)
+		}
+		tags = string(data)
+	}
+
+	var args interface{}
+	if len(j.Args) > 0 {
+		data, err := json.Marshal(j.Args)
+		if err != nil {
+			return fmt.Errorf("failed to encode args: %w", err)
+		}
+		args = string(data)
+	}
+
+	var env interface{}
+	if len(j.Env) > 0 {
+		data, err := json.Marshal(j.Env)
+		if err != nil {
+			return fmt.Errorf("failed to encode env: %w", err)
+		}
+		env = string(data)
+	}
+
+	var dedupKey interface{}
+	if j.DedupKey != "" {
+		dedupKey = j.DedupKey
+	}
+
+	// Stored in local time like every other timestamp so string comparisons hold
+	var runAt interface{}
+	if j.RunAt != nil {
+		runAt = j.RunAt.Local().Format(time.RFC3339)
+	}
+
+	_, err := tx.Exec(
+		query,
+		j.ID,
+		j.Queue,
+		tags,
+		j.Command,
+		args,
+		env,
+		j.Cwd,
+		payload,
+		string(j.PayloadMode),
+		string(j.State),
+		j.Attempts,
+		j.MaxRetries,
+		j.Priority,
+		time.Duration(j.Timeout).Milliseconds(),
+		retryPolicy,
+		exitRules,
+		j.CreatedAt.Format(time.RFC3339),
+		j.UpdatedAt.Format(time.RFC3339),
+		nil,
+		runAt,
+		string(j.DependencyPolicy),
+		dedupKey,
+	)
+	if err != nil {
+		// Check if it's a UNIQUE constraint error (duplicate ID)
+		if isUniqueViolation(err, "jobs.id") {
+			return fmt.Errorf("job with ID '%s' already exists: %w", j.ID, ErrDuplicateID)
+		}
+		if isUniqueViolation(err, "jobs.dedup_key") {
+			return errActiveDedupKey
+		}
+		return fmt.Errorf("failed to create job: %w", err)
+	}
+	return nil
+}
+
+// jobColumns lists the jobs table columns in the order scanJob reads them
+const jobColumns = `id, queue, tags, command, args, env, cwd, payload, payload_mode, result, state, attempts, max_retries, priority, timeout_ms, retry_policy, exit_rules, created_at, updated_at, next_retry_at,
+	COALESCE(lease_owner, ''), lease_expires_at, cancel_requested,
+	COALESCE(last_error, ''), last_exit_code, COALESCE(stderr_tail, ''), dead_at, run_at, dependency_policy, COALESCE(dedup_key, ''),
+	(SELECT group_concat(depends_on, char(10)) FROM job_dependencies WHERE job_id = jobs.id)`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanJob reads a job selected with jobColumns
+// Scan errors (including sql.ErrNoRows) are returned unwrapped
+func scanJob(row rowScanner) (*Job, error) {
+	var j Job
+	var timeoutMs int64
+	var createdAtStr, updatedAtStr string
+	var tagsStr, argsStr, envStr, payloadStr, resultStr, retryPolicyStr, exitRulesStr, nextRetryAtStr, leaseExpiresAtStr, runAtStr, dependsOnStr sql.NullString
+	var deadAtStr sql.NullString
+	var lastExitCode sql.NullInt64
+
+	err := row.Scan(
+		&j.ID,
+		&j.Queue,
+		&tagsStr,
+		&j.Command,
+		&argsStr,
+		&envStr,
+		&j.Cwd,
+		&payloadStr,
+		&j.PayloadMode,
+		&resultStr,
+		&j.State,
+		&j.Attempts,
+		&j.MaxRetries,
+		&j.Priority,
+		&timeoutMs,
+		&retryPolicyStr,
+		&exitRulesStr,
+		&createdAtStr,
+		&updatedAtStr,
+		&nextRetryAtStr,
+		&j.LeaseOwner,
+		&leaseExpiresAtStr,
+		&j.CancelRequested,
+		&j.LastError,
+		&lastExitCode,
+		&j.StderrTail,
+		&deadAtStr,
+		&runAtStr,
+		&j.DependencyPolicy,
+		&j.DedupKey,
+		&dependsOnStr,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	j.Timeout = Duration(time.Duration(timeoutMs) * time.Millisecond)
+
+	if tagsStr.Valid {
+		if err := json.Unmarshal([]byte(tagsStr.String), &j.Tags); err != nil {
+			return nil, fmt.Errorf("failed to parse tags: %w", err)
+		}
+	}
+
+	if argsStr.Valid {
+		if err := json.Unmarshal([]byte(argsStr.String), &j.Args); err != nil {
+			return nil, fmt.Errorf("failed to parse args: %w", err)
+		}
+	}
+
+	if retryPolicyStr.Valid {
+		j.Retry = &RetryPolicy{}
+		if err := json.Unmarshal([]byte(retryPolicyStr.String), j.Retry); err != nil {
+			return nil, fmt.Errorf("failed to parse retry policy: %w", err)
+		}
+	}
+
+	if exitRulesStr.Valid {
+		if err := json.Unmarshal([]byte(exitRulesStr.String), &j.ExitRules); err != nil {
+			return nil, fmt.Errorf("failed to parse exit rules: %w", err)
+		}
+	}
+
+	if payloadStr.Valid {
+		j.Payload = json.RawMessage(payloadStr.String)
+	}
+
+	if resultStr.Valid {
+		j.Result = json.RawMessage(resultStr.String)
+	}
+
+	if envStr.Valid {
+		if err := json.Unmarshal([]byte(envStr.String), &j.Env); err != nil {
+			return nil, fmt.Errorf("failed to parse env: %w", err)
+		}
+	}
+
+	// Parse timestamps
+	j.CreatedAt, err = time.Parse(time.RFC3339, createdAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse created_at: %w", err)
+	}
+
+	j.UpdatedAt, err = time.Parse(time.RFC3339, updatedAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse updated_at: %w", err)
+	}
+
+	if nextRetryAtStr.Valid {
+		nextRetryAt, err := time.Parse(time.RFC3339, nextRetryAtStr.String)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse next_retry_at: %w", err)
+		}
+		j.NextRetryAt = &nextRetryAt
+	}
+
+	if leaseExpiresAtStr.Valid {
+		leaseExpiresAt, err := time.Parse(time.RFC3339, leaseExpiresAtStr.String)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse lease_expires_at: %w", err)
+		}
+		j.LeaseExpiresAt = &leaseExpiresAt
+	}
+
+	if lastExitCode.Valid {
+		code := int(lastExitCode.Int64)
+		j.LastExitCode = &code
+	}
+
+	if deadAtStr.Valid {
+		deadAt, err := time.Parse(time.RFC3339, deadAtStr.String)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse dead_at: %w", err)
+		}
+		j.DeadAt = &deadAt
+	}
+
+	if runAtStr.Valid {
+		runAt, err := time.Parse(time.RFC3339, runAtStr.String)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse run_at: %w", err)
+		}
+		j.RunAt = &runAt
+	}
+
+	if dependsOnStr.Valid {
+		j.DependsOn = strings.Split(dependsOnStr.String, "\n")
+	}
+
+	return &j, nil
+}
+
+// GetByID retrieves a job by ID
+func GetByID(id string) (*Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs WHERE id = ?`
+
+	j, err := scanJob(db.GetDB().QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("job not found: %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	return j, nil
+}
+
+// GetByIDTx retrieves a job by ID within a transaction
+func GetByIDTx(tx *sql.Tx, id string) (*Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs WHERE id = ?`
+
+	j, err := scanJob(tx.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("job not found: %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	return j, nil
+}
+
+// UpdateState updates the job state and other fields
+func UpdateState(tx *sql.Tx, id string, state State, attempts int, nextRetryAt *time.Time) error {
+	now := time.Now()
+	query := `
+		UPDATE jobs
+		SET state = ?, attempts = ?, updated_at = ?, next_retry_at = ?
+		WHERE id = ?`
+
+	var nextRetryAtStr interface{}
+	if nextRetryAt != nil {
+		nextRetryAtStr = nextRetryAt.Format(time.RFC3339)
+	} else {
+		nextRetryAtStr = nil
+	}
+
+	_, err := tx.Exec(
+		query,
+		string(state),
+		attempts,
+		now.Format(time.RFC3339),
+		nextRetryAtStr,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update job state: %w", err)
+	}
+	return nil
+}
+
+// ListByState retrieves all jobs with a specific state
+// If queue is empty, jobs from every queue are returned
+func ListByState(state State, queue string) ([]*Job, error) {
+	query := `
+		SELECT ` + jobColumns + `
+		FROM jobs
+		WHERE state = ? AND (? = '' OR queue = ?)
+		ORDER BY created_at DESC`
+
+	rows, err := db.GetDB().Query(query, string(state), queue, queue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		jobs = append(jobs, j)
+	}
+
+	return jobs, nil
+}
+
+// ListScheduled retrieves jobs that are waiting for a future time: pending jobs
+// with run_at in the future and failed jobs waiting for their next retry.
+// Jobs are ordered by when they become eligible. If queue is empty, jobs from every queue are returned.
+func ListScheduled(queue string) ([]*Job, error) {
+	now := time.Now().Format(time.RFC3339)
+	query := `
+		SELECT ` + jobColumns + `
+		FROM jobs
+		WHERE ((state = ? AND run_at > ?) OR (state = ? AND next_retry_at > ?))
+		  AND (? = '' OR queue = ?)
+		ORDER BY COALESCE(next_retry_at, run_at) ASC`
+
+	rows, err := db.GetDB().Query(query, string(StatePending), now, string(StateFailed), now, queue, queue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scheduled jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		jobs = append(jobs, j)
+	}
+
+	return jobs, nil
+}
+
+// GetStats returns counts of jobs by state
+// If queue is empty, jobs from every queue are counted
+func GetStats(queue string) (map[State]int, error) {
+	query := `
+		SELECT state, COUNT(*) as count
+		FROM jobs
+		WHERE ? = '' OR queue = ?
+		GROUP BY state`
+
+	rows, err := db.GetDB().Query(query, queue, queue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := make(map[State]int)
+	for rows.Next() {
+		var stateStr string
+		var count int
+		if err := rows.Scan(&stateStr, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan stats: %w", err)
+		}
+		stats[State(stateStr)] = count
+	}
+
+	return stats, nil
+}
+
+// PriorityStats holds job counts by state for one priority level
+type PriorityStats struct {
+	Priority int           `json:"priority"`
+	Total    int           `json:"total"`
+	States   map[State]int `json:"states"`
+}
+
+// GetPriorityStats returns counts of jobs by state for each priority, highest priority first
+// If queue is empty, jobs from every queue are counted
+func GetPriorityStats(queue string) ([]*PriorityStats, error) {
+	query := `
+		SELECT priority, state, COUNT(*) as count
+		FROM jobs
+		WHERE ? = '' OR queue = ?
+		GROUP BY priority, state
+		ORDER BY priority DESC`
+
+	rows, err := db.GetDB().Query(query, queue, queue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get priority stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []*PriorityStats
+	for rows.Next() {
+		var priority, count int
+		var stateStr string
+		if err := rows.Scan(&priority, &stateStr, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan priority stats: %w", err)
+		}
+		if len(stats) == 0 || stats[len(stats)-1].Priority != priority {
+			stats = append(stats, &PriorityStats{Priority: priority, States: make(map[State]int)})
+		}
+		current := stats[len(stats)-1]
+		current.States[State(stateStr)] = count
+		current.Total += count
+	}
+
+	return stats, nil
+}
+
+// ListQueues returns the names of all queues that have jobs, with job counts by state
+func ListQueues() (map[string]map[State]int, error) {
+	query := `
+		SELECT queue, state, COUNT(*) as count
+		FROM jobs
+		GROUP BY queue, state`
+
+	rows, err := db.GetDB().Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queues: %w", err)
+	}
+	defer rows.Close()
+
+	queues := make(map[string]map[State]int)
+	for rows.Next() {
+		var queue, stateStr string
+		var count int
+		if err := rows.Scan(&queue, &stateStr, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan queue stats: %w", err)
+		}
+		if queues[queue] == nil {
+			queues[queue] = make(map[State]int)
+		}
+		queues[queue][State(stateStr)] = count
+	}
+
+	return queues, nil
+}
+
+// RetryDeadJob moves a dead job back to pending state
+// A job whose dependencies have not all completed goes back to blocked instead;
+// one depending on a dead or cancelled job is refused with ErrFailedDependency.
+func RetryDeadJob(id string) error {
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := retryOne(tx, id, ""); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
@@ -0,0 +1,52 @@
+package job
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// PayloadMode decides how a job's payload reaches its command
+type PayloadMode string
+
+const (
+	// PayloadStdin writes the payload to the command's stdin (the default)
+	PayloadStdin PayloadMode = "stdin"
+	// PayloadFile writes the payload to a temporary file named by QUEUECTL_PAYLOAD_FILE
+	PayloadFile PayloadMode = "file"
+)
+
+// attachPayload hands j's payload to cmd according to its payload mode
+// The returned cleanup function removes any temporary file and must be called
+// once the command has finished.
+func attachPayload(cmd *exec.Cmd, j *Job) (func(), error) {
+	if len(j.Payload) == 0 {
+		return func() {}, nil
+	}
+
+	if j.PayloadMode != PayloadFile {
+		cmd.Stdin = bytes.NewReader(j.Payload)
+		return func() {}, nil
+	}
+
+	f, err := os.CreateTemp("", "queuectl-payload-*.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create payload file: %w", err)
+	}
+	cleanup := func() { os.Remove(f.Name()) }
+
+	if _, err := f.Write(j.Payload); err != nil {
+		f.Close()
+		cleanup()
+		return nil, fmt.Errorf("failed to write payload file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to write payload file: %w", err)
+	}
+
+	cmd.Env = append(cmd.Env, "QUEUECTL_PAYLOAD_FILE="+f.Name())
+	return cleanup, nil
+}
+
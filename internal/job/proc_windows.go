@@ -0,0 +1,31 @@
+//go:build windows
+
+package job
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// exitSignal returns an empty string on Windows, where processes are not terminated by signals
+func exitSignal(state *os.ProcessState) string {
+	return ""
+}
+
+// setProcessGroup starts the command in a new process group
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// terminateProcessGroup asks the command's process tree to close
+func terminateProcessGroup(cmd *exec.Cmd) {
+	exec.Command("taskkill", "/T", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+}
+
+// killProcessGroup forcefully ends the command's process tree
+func killProcessGroup(cmd *exec.Cmd) {
+	exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+}
+
@@ -1,52 +1,230 @@
-package job
-
-import (
-	"fmt"
-	"os/exec"
-	"runtime"
-	"time"
-)
-
-// ExecuteResult represents the result of executing a job
-type ExecuteResult struct {
-	Success bool
-	Error   error
-}
-
-// Execute executes a job command and returns the result
-// Cross-platform: Uses sh -c on Unix/Linux/macOS, cmd /c on Windows
-func Execute(j *Job) ExecuteResult {
-	var cmd *exec.Cmd
-	
-	if runtime.GOOS == "windows" {
-		// Windows: Use cmd.exe /c for command execution
-		// This works with both CMD and PowerShell commands
-		cmd = exec.Command("cmd.exe", "/c", j.Command)
-	} else {
-		// Unix/Linux/macOS: Use sh -c for command execution
-		cmd = exec.Command("sh", "-c", j.Command)
-	}
-	
-	if err := cmd.Run(); err != nil {
-		return ExecuteResult{
-			Success: false,
-			Error:   fmt.Errorf("command failed: %w", err),
-		}
-	}
-
-	return ExecuteResult{
-		Success: true,
-		Error:   nil,
-	}
-}
-
-// CalculateNextRetry calculates the next retry time using exponential backoff
-func CalculateNextRetry(attempts int, backoffBase float64) time.Time {
-	// delay = base^attempts seconds
-	delaySeconds := int64(1)
-	for i := 0; i < attempts; i++ {
-		delaySeconds = int64(float64(delaySeconds) * backoffBase)
-	}
-	return time.Now().Add(time.Duration(delaySeconds) * time.Second)
-}
-
+package job
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// maxOutputBytes caps how much of each output stream is kept per attempt
+	maxOutputBytes = 1 << 20 // 1 MiB
+
+	// TimeoutGracePeriod is how long a timed out command may take to exit after SIGTERM
+	TimeoutGracePeriod = 5 * time.Second
+)
+
+// ExecuteResult represents the result of executing a job
+type ExecuteResult struct {
+	Success   bool
+	Error     error
+	Stdout    string
+	Stderr    string
+	ExitCode  int    // -1 if the process did not exit normally
+	Signal    string // name of the terminating signal, if any
+	TimedOut  bool
+	Cancelled bool
+	Result    json.RawMessage // JSON written to QUEUECTL_RESULT_FILE, if any
+}
+
+// outputBuffer collects command output up to maxOutputBytes and drops the rest
+type outputBuffer struct {
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	if remaining := maxOutputBytes - b.buf.Len(); remaining < len(p) {
+		b.truncated = true
+		if remaining > 0 {
+			b.buf.Write(p[:remaining])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *outputBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "\n[output truncated]\n"
+	}
+	return b.buf.String()
+}
+
+// ExecOptions describes one attempt at running a job
+type ExecOptions struct {
+	// Attempt is the attempt number exposed to the command as QUEUECTL_ATTEMPT
+	Attempt int
+	// Timeout kills the command when it expires. 0 means no timeout.
+	Timeout time.Duration
+}
+
+// Execute executes a job command and returns the result
+// Cross-platform: Uses sh -c on Unix/Linux/macOS, cmd /c on Windows.
+// Jobs given as args are executed directly without a shell.
+// The command runs in its own process group. If opts.Timeout is non-zero and expires,
+// or ctx is cancelled, the whole group is sent SIGTERM and, after TimeoutGracePeriod, SIGKILL.
+func Execute(ctx context.Context, j *Job, opts ExecOptions) ExecuteResult {
+	var cmd *exec.Cmd
+
+	if len(j.Args) > 0 {
+		// argv form: no shell, so no quoting or injection issues
+		cmd = exec.Command(j.Args[0], j.Args[1:]...)
+	} else if runtime.GOOS == "windows" {
+		// Windows: Use cmd.exe /c for command execution
+		// This works with both CMD and PowerShell commands
+		cmd = exec.Command("cmd.exe", "/c", j.Command)
+	} else {
+		// Unix/Linux/macOS: Use sh -c for command execution
+		cmd = exec.Command("sh", "-c", j.Command)
+	}
+
+	cmd.Dir = j.Cwd
+	cmd.Env = jobEnv(j, opts.Attempt)
+
+	cleanupPayload, err := attachPayload(cmd, j)
+	if err != nil {
+		return ExecuteResult{Error: err, Stderr: err.Error() + "\n", ExitCode: -1}
+	}
+	defer cleanupPayload()
+
+	resultPath, err := attachResultFile(cmd)
+	if err != nil {
+		return ExecuteResult{Error: err, Stderr: err.Error() + "\n", ExitCode: -1}
+	}
+	defer os.Remove(resultPath)
+
+	var stdout, stderr outputBuffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Don't wait forever on output pipes held open by orphaned background processes
+	cmd.WaitDelay = TimeoutGracePeriod
+	setProcessGroup(cmd)
+
+	err = cmd.Start()
+	if err != nil && j.Cwd != "" {
+		// exec reports a missing working directory as a missing program
+		if _, statErr := os.Stat(j.Cwd); statErr != nil {
+			err = fmt.Errorf("invalid working directory: %w", statErr)
+		}
+	}
+	timedOut, cancelled := false, false
+	if err == nil {
+		done := make(chan error, 1)
+		go func() {
+			done <- cmd.Wait()
+		}()
+
+		var deadline <-chan time.Time
+		if opts.Timeout > 0 {
+			timer := time.NewTimer(opts.Timeout)
+			defer timer.Stop()
+			deadline = timer.C
+		}
+
+		select {
+		case err = <-done:
+		case <-deadline:
+			timedOut = true
+			err = stopProcessGroup(cmd, done)
+		case <-ctx.Done():
+			cancelled = true
+			err = stopProcessGroup(cmd, done)
+		}
+	}
+
+	// The command exited 0 but a background process it started still held its
+	// output open; the attempt succeeded, only the output after exit is lost
+	outputCutOff := false
+	if errors.Is(err, exec.ErrWaitDelay) && cmd.ProcessState != nil && cmd.ProcessState.Success() {
+		err = nil
+		outputCutOff = true
+	}
+
+	result := ExecuteResult{
+		Success:   err == nil && !timedOut && !cancelled,
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		ExitCode:  -1,
+		TimedOut:  timedOut,
+		Cancelled: cancelled,
+	}
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+		result.Signal = exitSignal(cmd.ProcessState)
+	}
+	if outputCutOff {
+		result.Stderr += fmt.Sprintf("queuectl: command exited but a background process kept its output open for %s; later output was not captured\n", TimeoutGracePeriod)
+	}
+
+	if timedOut {
+		result.Error = fmt.Errorf("command timed out after %s", opts.Timeout)
+	} else if cancelled {
+		result.Error = fmt.Errorf("command cancelled")
+	} else if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			// The command could not be started at all
+			result.Stderr += err.Error() + "\n"
+		}
+		result.Error = fmt.Errorf("command failed: %w", err)
+	}
+
+	// A result that cannot be stored fails the attempt rather than being dropped
+	if result.Success {
+		if result.Result, err = readResult(resultPath); err != nil {
+			result.Success = false
+			result.Error = err
+			result.Stderr += err.Error() + "\n"
+		}
+	}
+
+	return result
+}
+
+// stopProcessGroup asks the command's process group to terminate and kills it
+// if it is still running after TimeoutGracePeriod. done receives the result of cmd.Wait.
+func stopProcessGroup(cmd *exec.Cmd, done <-chan error) error {
+	terminateProcessGroup(cmd)
+
+	grace := time.NewTimer(TimeoutGracePeriod)
+	defer grace.Stop()
+
+	select {
+	case err := <-done:
+		return err
+	case <-grace.C:
+		killProcessGroup(cmd)
+		return <-done
+	}
+}
+
+// jobEnv builds the environment of a job: the worker's environment, the job's
+// env and the QUEUECTL_* variables describing the job, later entries winning
+func jobEnv(j *Job, attempt int) []string {
+	env := os.Environ()
+
+	names := make([]string, 0, len(j.Env))
+	for name := range j.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+j.Env[name])
+	}
+
+	return append(env,
+		"QUEUECTL_JOB_ID="+j.ID,
+		"QUEUECTL_ATTEMPT="+strconv.Itoa(attempt),
+		"QUEUECTL_QUEUE="+j.Queue,
+	)
+}
+
@@ -0,0 +1,72 @@
+package job
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// maxResultBytes caps the size of the JSON result a job may write
+const maxResultBytes = 1 << 20 // 1 MiB
+
+// attachResultFile creates an empty temporary file for the command to write its
+// JSON result to and exposes its path as QUEUECTL_RESULT_FILE
+func attachResultFile(cmd *exec.Cmd) (string, error) {
+	f, err := os.CreateTemp("", "queuectl-result-*.json")
+	if err != nil {
+		return "", fmt.Errorf("failed to create result file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to create result file: %w", err)
+	}
+
+	cmd.Env = append(cmd.Env, "QUEUECTL_RESULT_FILE="+f.Name())
+	return f.Name(), nil
+}
+
+// readResult reads the JSON a command wrote to its result file
+// It returns nil if the command wrote nothing.
+func readResult(path string) (json.RawMessage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open result file: %w", err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxResultBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read result file: %w", err)
+	}
+	if len(data) > maxResultBytes {
+		return nil, fmt.Errorf("result is larger than %d bytes", maxResultBytes)
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("result file does not contain valid JSON")
+	}
+	return json.RawMessage(data), nil
+}
+
+// SaveResult stores the JSON result of a job's successful attempt
+func SaveResult(tx *sql.Tx, jobID string, result json.RawMessage) error {
+	_, err := tx.Exec(
+		`UPDATE jobs SET result = ?, updated_at = ? WHERE id = ?`,
+		string(result),
+		time.Now().Format(time.RFC3339),
+		jobID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save job result: %w", err)
+	}
+	return nil
+}
+
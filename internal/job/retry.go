@@ -0,0 +1,124 @@
+package job
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryStrategy is how the delay before a retry grows with the number of attempts
+type RetryStrategy string
+
+const (
+	// RetryFixed waits Delay before every retry
+	RetryFixed RetryStrategy = "fixed"
+	// RetryLinear waits Delay * attempts
+	RetryLinear RetryStrategy = "linear"
+	// RetryExponential waits Delay * Base^(attempts-1)
+	RetryExponential RetryStrategy = "exponential"
+	// RetryExponentialJitter waits a random time between 0 and the exponential delay
+	RetryExponentialJitter RetryStrategy = "exponential-jitter"
+)
+
+// maxBackoff keeps uncapped delays from overflowing time.Duration
+const maxBackoff = 100 * 365 * 24 * time.Hour
+
+// RetryPolicy decides how long a failed job waits before its next attempt
+// The global policy comes from the config; a job can override any of its fields.
+type RetryPolicy struct {
+	Strategy RetryStrategy `json:"strategy,omitempty"`
+	// Delay is the fixed delay, the linear step or the first exponential delay.
+	// 0 means Base seconds, so the default exponential policy waits Base^attempts seconds.
+	Delay Duration `json:"delay,omitempty"`
+	// Base is the growth factor of the exponential strategies
+	Base     float64  `json:"base,omitempty"`
+	MinDelay Duration `json:"min_delay,omitempty"`
+	MaxDelay Duration `json:"max_delay,omitempty"` // 0 = no cap
+}
+
+// Validate checks the strategy and delays of a policy
+func (p *RetryPolicy) Validate() error {
+	switch p.Strategy {
+	case "", RetryFixed, RetryLinear, RetryExponential, RetryExponentialJitter:
+	default:
+		return fmt.Errorf("invalid retry strategy '%s' (must be fixed, linear, exponential or exponential-jitter)", p.Strategy)
+	}
+	if p.Base < 0 {
+		return fmt.Errorf("retry base must be positive")
+	}
+	if p.Delay < 0 || p.MinDelay < 0 || p.MaxDelay < 0 {
+		return fmt.Errorf("retry delays must be non-negative")
+	}
+	if p.MaxDelay > 0 && p.MinDelay > p.MaxDelay {
+		return fmt.Errorf("retry min_delay must not exceed max_delay")
+	}
+	return nil
+}
+
+// Override returns p with every field that is set in o replaced
+func (p RetryPolicy) Override(o *RetryPolicy) RetryPolicy {
+	if o == nil {
+		return p
+	}
+	if o.Strategy != "" {
+		p.Strategy = o.Strategy
+	}
+	if o.Delay != 0 {
+		p.Delay = o.Delay
+	}
+	if o.Base != 0 {
+		p.Base = o.Base
+	}
+	if o.MinDelay != 0 {
+		p.MinDelay = o.MinDelay
+	}
+	if o.MaxDelay != 0 {
+		p.MaxDelay = o.MaxDelay
+	}
+	return p
+}
+
+// Backoff returns how long to wait before retrying after the given number of failed attempts
+func (p RetryPolicy) Backoff(attempts int) time.Duration {
+	base := p.Base
+	if base <= 0 {
+		base = 2
+	}
+	delay := float64(p.Delay)
+	if delay == 0 {
+		delay = base * float64(time.Second)
+	}
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	switch p.Strategy {
+	case RetryFixed:
+	case RetryLinear:
+		delay *= float64(attempts)
+	default:
+		delay *= math.Pow(base, float64(attempts-1))
+	}
+
+	limit := float64(maxBackoff)
+	if p.MaxDelay > 0 {
+		limit = float64(p.MaxDelay)
+	}
+	d := time.Duration(math.Min(delay, limit))
+
+	if p.Strategy == RetryExponentialJitter && d > 0 {
+		// Full jitter spreads out jobs that failed together
+		d = time.Duration(rand.Int63n(int64(d) + 1))
+	}
+	if d < time.Duration(p.MinDelay) {
+		d = time.Duration(p.MinDelay)
+	}
+	return d
+}
+
+// NextRetry returns when a job that has failed the given number of attempts should run again
+func (p RetryPolicy) NextRetry(attempts int) time.Time {
+	return time.Now().Add(p.Backoff(attempts))
+}
+
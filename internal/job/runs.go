@@ -0,0 +1,211 @@
+package job
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"queuectl/internal/db"
+)
+
+// RunStatus represents the outcome of a single attempt
+type RunStatus string
+
+const (
+	RunRunning   RunStatus = "running"
+	RunSucceeded RunStatus = "succeeded"
+	RunFailed    RunStatus = "failed"
+	RunTimedOut  RunStatus = "timeout"
+	RunAbandoned RunStatus = "abandoned"
+	RunCancelled RunStatus = "cancelled"
+)
+
+// Run represents one execution attempt of a job
+type Run struct {
+	ID         int64      `json:"id"`
+	JobID      string     `json:"job_id"`
+	Attempt    int        `json:"attempt"`
+	WorkerID   string     `json:"worker_id"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	DurationMs int64      `json:"duration_ms"`
+	Status     RunStatus  `json:"status"`
+	ExitCode   *int       `json:"exit_code,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// StartRun records the start of a new attempt of a job by a worker
+// Attempts are numbered per job in execution order, starting at 1
+func StartRun(jobID, workerID string) (*Run, error) {
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Older databases may have logs without matching runs, so number past both
+	var attempt int
+	err = tx.QueryRow(`
+		SELECT MAX(
+			(SELECT COALESCE(MAX(attempt), 0) FROM job_runs WHERE job_id = ?),
+			(SELECT COALESCE(MAX(attempt), 0) FROM job_logs WHERE job_id = ?)
+		) + 1`,
+		jobID, jobID,
+	).Scan(&attempt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get next attempt number: %w", err)
+	}
+
+	run := &Run{
+		JobID:     jobID,
+		Attempt:   attempt,
+		WorkerID:  workerID,
+		StartedAt: time.Now(),
+		Status:    RunRunning,
+	}
+
+	query := `
+		INSERT INTO job_runs (job_id, attempt, worker_id, started_at, status)
+		VALUES (?, ?, ?, ?, ?)`
+
+	res, err := tx.Exec(
+		query,
+		run.JobID,
+		run.Attempt,
+		run.WorkerID,
+		run.StartedAt.Format(time.RFC3339),
+		string(run.Status),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record job run: %w", err)
+	}
+
+	run.ID, err = res.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get run id: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return run, nil
+}
+
+// FinishRun records the outcome of an attempt
+func FinishRun(tx *sql.Tx, run *Run, status RunStatus, result ExecuteResult) error {
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.DurationMs = finishedAt.Sub(run.StartedAt).Milliseconds()
+	run.Status = status
+	exitCode := result.ExitCode
+	run.ExitCode = &exitCode
+	if result.Error != nil {
+		run.Error = result.Error.Error()
+	}
+
+	query := `
+		UPDATE job_runs
+		SET finished_at = ?, duration_ms = ?, status = ?, exit_code = ?, error = ?
+		WHERE id = ?`
+
+	_, err := tx.Exec(
+		query,
+		finishedAt.Format(time.RFC3339),
+		run.DurationMs,
+		string(run.Status),
+		exitCode,
+		run.Error,
+		run.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update job run: %w", err)
+	}
+	return nil
+}
+
+// abandonRuns closes the unfinished runs of a job whose worker went away
+func abandonRuns(tx *sql.Tx, jobID string, now time.Time) error {
+	query := `
+		UPDATE job_runs
+		SET finished_at = ?, status = ?, error = ?,
+		    duration_ms = (strftime('%s', ?) - strftime('%s', started_at)) * 1000
+		WHERE job_id = ? AND status = ?`
+
+	nowStr := now.Format(time.RFC3339)
+	_, err := tx.Exec(
+		query,
+		nowStr,
+		string(RunAbandoned),
+		"worker lease expired",
+		nowStr,
+		jobID,
+		string(RunRunning),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to close abandoned runs: %w", err)
+	}
+	return nil
+}
+
+// ListRuns retrieves the execution history of a job, oldest attempt first
+func ListRuns(jobID string) ([]*Run, error) {
+	query := `
+		SELECT id, job_id, attempt, worker_id, started_at, finished_at, duration_ms, status, exit_code, error
+		FROM job_runs
+		WHERE job_id = ?
+		ORDER BY attempt ASC`
+
+	rows, err := db.GetDB().Query(query, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list job runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []*Run{}
+	for rows.Next() {
+		var r Run
+		var startedAtStr string
+		var finishedAtStr sql.NullString
+		var exitCode sql.NullInt64
+
+		err := rows.Scan(
+			&r.ID,
+			&r.JobID,
+			&r.Attempt,
+			&r.WorkerID,
+			&startedAtStr,
+			&finishedAtStr,
+			&r.DurationMs,
+			&r.Status,
+			&exitCode,
+			&r.Error,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job run: %w", err)
+		}
+
+		r.StartedAt, err = time.Parse(time.RFC3339, startedAtStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse started_at: %w", err)
+		}
+
+		if finishedAtStr.Valid {
+			finishedAt, err := time.Parse(time.RFC3339, finishedAtStr.String)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse finished_at: %w", err)
+			}
+			r.FinishedAt = &finishedAt
+		}
+
+		if exitCode.Valid {
+			code := int(exitCode.Int64)
+			r.ExitCode = &code
+		}
+
+		runs = append(runs, &r)
+	}
+
+	return runs, rows.Err()
+}
+
@@ -0,0 +1,218 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Expression is a parsed five-field cron expression:
+// minute hour day-of-month month day-of-week
+type Expression struct {
+	minute, hour, dom, month, dow uint64
+
+	// When both day fields are restricted a day matches if either does,
+	// following the usual cron convention
+	domAny, dowAny bool
+}
+
+// field describes the allowed range and names of one cron field
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day-of-month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowField = field{name: "day-of-week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// macros are the supported @shorthands
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a cron expression such as "*/15 9-17 * * MON-FRI" or "@daily"
+func Parse(spec string) (*Expression, error) {
+	spec = strings.TrimSpace(spec)
+	if expanded, ok := macros[strings.ToLower(spec)]; ok {
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression must have 5 fields (minute hour day-of-month month day-of-week), got %d", len(fields))
+	}
+
+	var e Expression
+	var err error
+	if e.minute, err = parseField(fields[0], minuteField); err != nil {
+		return nil, err
+	}
+	if e.hour, err = parseField(fields[1], hourField); err != nil {
+		return nil, err
+	}
+	if e.dom, err = parseField(fields[2], domField); err != nil {
+		return nil, err
+	}
+	if e.month, err = parseField(fields[3], monthField); err != nil {
+		return nil, err
+	}
+	if e.dow, err = parseField(fields[4], dowField); err != nil {
+		return nil, err
+	}
+
+	// 7 is an alias for Sunday
+	if e.dow&(1<<7) != 0 {
+		e.dow |= 1
+		e.dow &^= 1 << 7
+	}
+
+	// Like Vixie cron, a field starting with '*' (such as "*/2") counts as unrestricted
+	e.domAny = strings.HasPrefix(fields[2], "*") || strings.HasPrefix(fields[2], "?")
+	e.dowAny = strings.HasPrefix(fields[4], "*") || strings.HasPrefix(fields[4], "?")
+
+	return &e, nil
+}
+
+// parseField parses one comma-separated cron field into a bitset of allowed values
+func parseField(value string, f field) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(value, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			s, err := strconv.Atoi(stepPart)
+			if err != nil || s < 1 {
+				return 0, fmt.Errorf("invalid step '%s' in %s field", stepPart, f.name)
+			}
+			step = s
+		}
+
+		var lo, hi int
+		switch {
+		case rangePart == "*" || rangePart == "?":
+			lo, hi = f.min, f.max
+		case strings.Contains(rangePart, "-"):
+			loStr, hiStr, _ := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = parseValue(loStr, f); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(hiStr, f); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range '%s' in %s field", rangePart, f.name)
+			}
+		default:
+			v, err := parseValue(rangePart, f)
+			if err != nil {
+				return 0, err
+			}
+			lo, hi = v, v
+			if hasStep {
+				// "5/15" means every 15 starting at 5
+				hi = f.max
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// parseValue parses a single number or name within a field's range
+func parseValue(value string, f field) (int, error) {
+	if v, ok := f.names[strings.ToLower(value)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value '%s' in %s field", value, f.name)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("value %d out of range %d-%d in %s field", v, f.min, f.max, f.name)
+	}
+	return v, nil
+}
+
+// Next returns the first time strictly after t that matches the expression, in t's location
+// It returns the zero time if nothing matches within five years (e.g. "0 0 30 2 *").
+func (e *Expression) Next(t time.Time) time.Time {
+	loc := t.Location()
+	from := wallClock(t)
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+	limit := t.Year() + 5
+
+	for t.Year() <= limit {
+		if e.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !e.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if e.hour&(1<<uint(t.Hour())) == 0 {
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			if !next.After(t) {
+				// Clocks went back; step over the repeated hour
+				next = t.Add(time.Hour).Truncate(time.Hour)
+			}
+			t = next
+			continue
+		}
+		if e.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		if e.hour != allHours && !wallClock(t).After(from) {
+			// Clocks went back and this run already happened during the first pass of the
+			// repeated hour; like cron, runs at a fixed hour are not made twice
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// allHours is the hour bitset of an expression that runs every hour
+const allHours = 1<<24 - 1
+
+// wallClock returns t's local date and time to the minute, stripped of its zone,
+// so that times on either side of a DST change compare by what the clock showed
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+}
+
+// dayMatches reports whether t's day satisfies the day-of-month and day-of-week fields
+func (e *Expression) dayMatches(t time.Time) bool {
+	domMatch := e.dom&(1<<uint(t.Day())) != 0
+	dowMatch := e.dow&(1<<uint(t.Weekday())) != 0
+	if e.domAny || e.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
@@ -0,0 +1,188 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"foo * * * *",
+		"* * * jan-foo *",
+		"@every",
+	}
+	for _, spec := range tests {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		spec   string
+		field  func(*Expression) uint64
+		values []int
+	}{
+		{"0 * * * *", minuteOf, []int{0}},
+		{"1,5,59 * * * *", minuteOf, []int{1, 5, 59}},
+		{"10-13 * * * *", minuteOf, []int{10, 11, 12, 13}},
+		{"*/15 * * * *", minuteOf, []int{0, 15, 30, 45}},
+		{"5/20 * * * *", minuteOf, []int{5, 25, 45}},
+		{"10-20/5 * * * *", minuteOf, []int{10, 15, 20}},
+		{"* 9-17/4 * * *", hourOf, []int{9, 13, 17}},
+		{"* * 1,15 * *", domOf, []int{1, 15}},
+		{"* * * JAN,mar-May *", monthOf, []int{1, 3, 4, 5}},
+		{"* * * * MON-FRI", dowOf, []int{1, 2, 3, 4, 5}},
+		{"* * * * sun", dowOf, []int{0}},
+		{"* * * * 7", dowOf, []int{0}},
+		{"* * * * 5-7", dowOf, []int{0, 5, 6}},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.spec)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.spec, err)
+			continue
+		}
+		var want uint64
+		for _, v := range tt.values {
+			want |= 1 << uint(v)
+		}
+		if got := tt.field(e); got != want {
+			t.Errorf("Parse(%q) = %b, want %b", tt.spec, got, want)
+		}
+	}
+}
+
+func minuteOf(e *Expression) uint64 { return e.minute }
+func hourOf(e *Expression) uint64   { return e.hour }
+func domOf(e *Expression) uint64    { return e.dom }
+func monthOf(e *Expression) uint64  { return e.month }
+func dowOf(e *Expression) uint64    { return e.dow }
+
+func TestParseMacros(t *testing.T) {
+	tests := map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@MIDNIGHT": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+	for macro, spec := range tests {
+		got, err := Parse(macro)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", macro, err)
+			continue
+		}
+		want, err := Parse(spec)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", spec, err)
+		}
+		if *got != *want {
+			t.Errorf("Parse(%q) = %+v, want %+v", macro, *got, *want)
+		}
+	}
+}
+
+func TestDayFieldsRule(t *testing.T) {
+	tests := []struct {
+		spec   string
+		domAny bool
+		dowAny bool
+	}{
+		{"0 0 * * *", true, true},
+		{"0 0 ? * ?", true, true},
+		{"0 0 */2 * MON", true, false},
+		{"0 0 1 * */1", false, true},
+		{"0 0 1 * MON", false, false},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.spec)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.spec, err)
+		}
+		if e.domAny != tt.domAny || e.dowAny != tt.dowAny {
+			t.Errorf("Parse(%q): domAny=%v dowAny=%v, want %v %v", tt.spec, e.domAny, e.dowAny, tt.domAny, tt.dowAny)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	utc := time.UTC
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"next minute", "* * * * *", date(utc, 2024, 5, 10, 12, 30), date(utc, 2024, 5, 10, 12, 31)},
+		{"strictly after", "30 12 * * *", date(utc, 2024, 5, 10, 12, 30), date(utc, 2024, 5, 11, 12, 30)},
+		{"seconds dropped", "* * * * *", time.Date(2024, 5, 10, 12, 30, 59, 0, utc), date(utc, 2024, 5, 10, 12, 31)},
+		{"end of 30-day month", "0 0 31 * *", date(utc, 2024, 4, 1, 0, 0), date(utc, 2024, 5, 31, 0, 0)},
+		{"end of year", "0 0 1 * *", date(utc, 2024, 12, 15, 0, 0), date(utc, 2025, 1, 1, 0, 0)},
+		{"leap day", "0 0 29 2 *", date(utc, 2024, 3, 1, 0, 0), date(utc, 2028, 2, 29, 0, 0)},
+		{"leap day this year", "0 12 29 2 *", date(utc, 2024, 2, 1, 0, 0), date(utc, 2024, 2, 29, 12, 0)},
+		{"day of month or day of week", "0 0 13 * FRI", date(utc, 2024, 9, 1, 0, 0), date(utc, 2024, 9, 6, 0, 0)},
+		{"starred day of month is unrestricted", "0 0 */2 * MON", date(utc, 2024, 9, 1, 0, 0), date(utc, 2024, 9, 9, 0, 0)},
+		{"starred day of month needs both fields", "0 0 */2 * MON", date(utc, 2024, 9, 9, 0, 0), date(utc, 2024, 9, 23, 0, 0)},
+		{"sunday as 7", "0 9 * * 7", date(utc, 2024, 9, 2, 0, 0), date(utc, 2024, 9, 8, 9, 0)},
+		{"spring forward skips missing hour", "30 2 * * *", date(berlin, 2024, 3, 30, 3, 0), date(berlin, 2024, 4, 1, 2, 30)},
+		{"spring forward hourly", "0 * * * *", date(berlin, 2024, 3, 31, 1, 30), date(berlin, 2024, 3, 31, 3, 0)},
+		{"fall back hourly runs in the repeated hour", "0 * * * *", time.Date(2024, 11, 3, 5, 30, 0, 0, utc).In(newYork), time.Date(2024, 11, 3, 6, 0, 0, 0, utc)},
+		{"fall back fixed hour runs once", "30 1 * * *", time.Date(2024, 11, 3, 5, 30, 0, 0, utc).In(newYork), date(newYork, 2024, 11, 4, 1, 30)},
+		{"fall back fixed hour before the change", "45 1 * * *", time.Date(2024, 11, 3, 5, 30, 0, 0, utc).In(newYork), time.Date(2024, 11, 3, 5, 45, 0, 0, utc)},
+		{"impossible date", "0 0 30 2 *", date(utc, 2024, 1, 1, 0, 0), time.Time{}},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.spec)
+		if err != nil {
+			t.Fatalf("%s: Parse(%q): %v", tt.name, tt.spec, err)
+		}
+		if got := e.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%s: Next(%q, %s) = %s, want %s", tt.name, tt.spec, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestNextInLocation(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := Parse("0 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := e.Next(date(tokyo, 2024, 5, 10, 10, 0))
+	if got.Location() != tokyo || !got.Equal(date(tokyo, 2024, 5, 11, 9, 0)) {
+		t.Errorf("Next = %s, want 2024-05-11 09:00 in Asia/Tokyo", got)
+	}
+}
+
+func date(loc *time.Location, year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, loc)
+}
@@ -0,0 +1,463 @@
+package schedule
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	_ "time/tzdata" // make every timezone available, including on Windows
+
+	"queuectl/internal/db"
+	"queuectl/internal/job"
+)
+
+// CatchUpPolicy decides what happens to runs missed while no worker was running
+type CatchUpPolicy string
+
+const (
+	// CatchUpSkip drops missed runs and only enqueues runs that are on time
+	CatchUpSkip CatchUpPolicy = "skip"
+	// CatchUpOnce enqueues a single run for any number of missed runs
+	CatchUpOnce CatchUpPolicy = "once"
+	// CatchUpAll enqueues every missed run, up to maxCatchUpRuns
+	CatchUpAll CatchUpPolicy = "all"
+)
+
+const (
+	// maxCatchUpRuns caps how many missed runs CatchUpAll enqueues at once
+	maxCatchUpRuns = 100
+
+	// onTimeGrace is how late a run may be and still count as on time for CatchUpSkip
+	onTimeGrace = time.Minute
+)
+
+// namePattern restricts schedule names, which become part of generated job IDs
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Schedule is a recurring job definition
+type Schedule struct {
+	Name        string          `json:"name"`
+	Cron        string          `json:"cron"`
+	Timezone    string          `json:"timezone"`
+	JobTemplate json.RawMessage `json:"job_template"`
+	CatchUp     CatchUpPolicy   `json:"catch_up"`
+	Paused      bool            `json:"paused"`
+	NextRunAt   time.Time       `json:"next_run_at"`
+	LastRunAt   *time.Time      `json:"last_run_at,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// Validate checks the cron expression, timezone, catch-up policy and job template
+func (s *Schedule) Validate() error {
+	if !namePattern.MatchString(s.Name) {
+		return fmt.Errorf("invalid schedule name '%s' (use letters, digits, '.', '_' or '-')", s.Name)
+	}
+	if _, _, err := s.compile(); err != nil {
+		return err
+	}
+	switch s.CatchUp {
+	case CatchUpSkip, CatchUpOnce, CatchUpAll:
+	default:
+		return fmt.Errorf("invalid catch-up policy '%s' (must be skip, once or all)", s.CatchUp)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(s.JobTemplate, &fields); err != nil {
+		return fmt.Errorf("job template must be a JSON object: %w", err)
+	}
+	if _, ok := fields["id"]; ok {
+		return fmt.Errorf("job template must not set an id; job IDs are generated per run")
+	}
+	if _, err := s.newJob(time.Now()); err != nil {
+		return fmt.Errorf("invalid job template: %w", err)
+	}
+	return nil
+}
+
+// compile parses the cron expression and loads the timezone
+func (s *Schedule) compile() (*Expression, *time.Location, error) {
+	expr, err := Parse(s.Cron)
+	if err != nil {
+		return nil, nil, err
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unknown timezone '%s'", s.Timezone)
+	}
+	return expr, loc, nil
+}
+
+// next returns the first run time strictly after t in the schedule's timezone
+func (s *Schedule) next(t time.Time) (time.Time, error) {
+	expr, loc, err := s.compile()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return expr.Next(t.In(loc)), nil
+}
+
+// newJob builds the concrete job for the run at runAt
+// The ID is derived from the schedule name and run time, so the same run is never enqueued twice
+func (s *Schedule) newJob(runAt time.Time) (*job.Job, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(s.JobTemplate, &fields); err != nil {
+		return nil, fmt.Errorf("failed to parse job template: %w", err)
+	}
+
+	id, err := json.Marshal(fmt.Sprintf("%s@%s", s.Name, runAt.UTC().Format("20060102T150405Z")))
+	if err != nil {
+		return nil, err
+	}
+	fields["id"] = id
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build job: %w", err)
+	}
+	return job.FromJSON(string(data))
+}
+
+// Add validates and stores a new schedule; its first run is the next match after now
+func Add(s *Schedule) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	next, err := s.next(now)
+	if err != nil {
+		return err
+	}
+	if next.IsZero() {
+		return fmt.Errorf("cron expression '%s' never matches", s.Cron)
+	}
+	s.NextRunAt = next
+	s.CreatedAt = now
+
+	query := `
+		INSERT INTO schedules (name, cron, timezone, job_template, catch_up, paused, next_run_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err = db.GetDB().Exec(
+		query,
+		s.Name,
+		s.Cron,
+		s.Timezone,
+		string(s.JobTemplate),
+		string(s.CatchUp),
+		s.Paused,
+		s.NextRunAt.Format(time.RFC3339),
+		s.CreatedAt.Format(time.RFC3339),
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return fmt.Errorf("schedule '%s' already exists", s.Name)
+		}
+		return fmt.Errorf("failed to create schedule: %w", err)
+	}
+	return nil
+}
+
+// scheduleColumns lists the schedules table columns in the order scanSchedule reads them
+const scheduleColumns = `name, cron, timezone, job_template, catch_up, paused, next_run_at, last_run_at, created_at`
+
+// scanSchedule reads a schedule selected with scheduleColumns
+func scanSchedule(row interface{ Scan(...interface{}) error }) (*Schedule, error) {
+	var s Schedule
+	var template, nextRunAtStr, createdAtStr string
+	var lastRunAtStr sql.NullString
+
+	err := row.Scan(
+		&s.Name,
+		&s.Cron,
+		&s.Timezone,
+		&template,
+		&s.CatchUp,
+		&s.Paused,
+		&nextRunAtStr,
+		&lastRunAtStr,
+		&createdAtStr,
+	)
+	if err != nil {
+		return nil, err
+	}
+	s.JobTemplate = json.RawMessage(template)
+
+	s.NextRunAt, err = time.Parse(time.RFC3339, nextRunAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse next_run_at: %w", err)
+	}
+
+	s.CreatedAt, err = time.Parse(time.RFC3339, createdAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse created_at: %w", err)
+	}
+
+	if lastRunAtStr.Valid {
+		lastRunAt, err := time.Parse(time.RFC3339, lastRunAtStr.String)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse last_run_at: %w", err)
+		}
+		s.LastRunAt = &lastRunAt
+	}
+
+	return &s, nil
+}
+
+// Get retrieves a schedule by name
+func Get(name string) (*Schedule, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM schedules WHERE name = ?`
+
+	s, err := scanSchedule(db.GetDB().QueryRow(query, name))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("schedule not found: %s", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule: %w", err)
+	}
+	return s, nil
+}
+
+// List retrieves all schedules ordered by name
+func List() ([]*Schedule, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM schedules ORDER BY name ASC`
+
+	rows, err := db.GetDB().Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := []*Schedule{}
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan schedule: %w", err)
+		}
+		schedules = append(schedules, s)
+	}
+
+	return schedules, rows.Err()
+}
+
+// Remove deletes a schedule; jobs it already enqueued are left alone
+func Remove(name string) error {
+	result, err := db.GetDB().Exec(`DELETE FROM schedules WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("failed to remove schedule: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("schedule not found: %s", name)
+	}
+	return nil
+}
+
+// Pause stops a schedule from enqueueing jobs
+func Pause(name string) error {
+	result, err := db.GetDB().Exec(`UPDATE schedules SET paused = 1 WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("failed to pause schedule: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("schedule not found: %s", name)
+	}
+	return nil
+}
+
+// Resume re-enables a paused schedule
+// Runs that fell inside the pause are not caught up; the next run is the next match after now.
+func Resume(name string) (*Schedule, error) {
+	s, err := Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := s.next(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if next.IsZero() {
+		return nil, fmt.Errorf("cron expression '%s' never matches", s.Cron)
+	}
+
+	_, err = db.GetDB().Exec(
+		`UPDATE schedules SET paused = 0, next_run_at = ? WHERE name = ?`,
+		next.Format(time.RFC3339),
+		name,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resume schedule: %w", err)
+	}
+
+	s.Paused = false
+	s.NextRunAt = next
+	return s, nil
+}
+
+// RunDue enqueues jobs for every active schedule that is due at now and
+// returns the IDs of the jobs created. It is safe to call from several
+// processes at once: each due run is claimed by exactly one caller.
+func RunDue(now time.Time) ([]string, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM schedules WHERE paused = 0`
+
+	rows, err := db.GetDB().Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+
+	var due []*Schedule
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan schedule: %w", err)
+		}
+		if !s.NextRunAt.After(now) {
+			due = append(due, s)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+	rows.Close()
+
+	// One failing schedule must not hold up the others
+	var created []string
+	var errs []error
+	for _, s := range due {
+		ids, err := fire(s, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("schedule %s: %w", s.Name, err))
+			continue
+		}
+		created = append(created, ids...)
+	}
+	return created, errors.Join(errs...)
+}
+
+// disable pauses a schedule that cannot be evaluated so that it stops failing on every tick
+func disable(s *Schedule, cause error) error {
+	if _, err := db.GetDB().Exec(`UPDATE schedules SET paused = 1 WHERE name = ?`, s.Name); err != nil {
+		return fmt.Errorf("%w (and failed to pause it: %v)", cause, err)
+	}
+	return fmt.Errorf("%w; schedule paused", cause)
+}
+
+// missedRuns returns the latest run times from from up to now, oldest first, at most maxCatchUpRuns of them
+// Rather than stepping through every run since from, it looks back over a window
+// that doubles until it holds enough runs or reaches from.
+func missedRuns(expr *Expression, loc *time.Location, from, now time.Time) []time.Time {
+	for window := time.Hour; ; window *= 2 {
+		first := from
+		if start := now.Add(-window); start.After(from) {
+			first = expr.Next(start.In(loc))
+		}
+
+		var runs []time.Time
+		for t := first; !t.IsZero() && !t.After(now); t = expr.Next(t) {
+			runs = append(runs, t)
+			if len(runs) > maxCatchUpRuns {
+				runs = runs[1:]
+			}
+		}
+
+		if first.Equal(from) || len(runs) >= maxCatchUpRuns {
+			return runs
+		}
+	}
+}
+
+// fire enqueues the runs of a due schedule according to its catch-up policy
+// and advances next_run_at. The advance is a compare-and-swap on the old
+// next_run_at, so when several schedulers race only one of them enqueues.
+func fire(s *Schedule, now time.Time) ([]string, error) {
+	expr, loc, err := s.compile()
+	if err != nil {
+		return nil, disable(s, err)
+	}
+
+	next := expr.Next(now.In(loc))
+	if next.IsZero() {
+		return nil, disable(s, fmt.Errorf("cron expression '%s' never matches", s.Cron))
+	}
+
+	missed := missedRuns(expr, loc, s.NextRunAt.In(loc), now)
+
+	var runs []time.Time
+	if len(missed) > 0 {
+		latest := missed[len(missed)-1]
+		switch s.CatchUp {
+		case CatchUpAll:
+			runs = missed
+		case CatchUpOnce:
+			runs = []time.Time{latest}
+		case CatchUpSkip:
+			if now.Sub(latest) <= onTimeGrace {
+				runs = []time.Time{latest}
+			}
+		}
+	}
+
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE schedules SET next_run_at = ?, last_run_at = ? WHERE name = ? AND next_run_at = ? AND paused = 0`,
+		next.Format(time.RFC3339),
+		now.Format(time.RFC3339),
+		s.Name,
+		s.NextRunAt.Format(time.RFC3339),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to advance schedule: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		// Another scheduler got there first
+		return nil, nil
+	}
+
+	var created []string
+	for _, runAt := range runs {
+		j, err := s.newJob(runAt)
+		if err != nil {
+			return nil, err
+		}
+		outcome, err := job.CreateTx(tx, j)
+		if err != nil {
+			if errors.Is(err, job.ErrDuplicateID) || errors.Is(err, job.ErrDuplicateKey) {
+				continue
+			}
+			return nil, err
+		}
+		if outcome != job.OutcomeExisting {
+			created = append(created, j.ID)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return created, nil
+}
+
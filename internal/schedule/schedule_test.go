@@ -0,0 +1,68 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMissedRuns(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 30, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		spec  string
+		from  time.Time
+		count int
+		first time.Time
+		last  time.Time
+	}{
+		{
+			name:  "month of every-minute runs keeps the latest",
+			spec:  "* * * * *",
+			from:  time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+			count: maxCatchUpRuns,
+			first: now.Truncate(time.Minute).Add(-(maxCatchUpRuns - 1) * time.Minute),
+			last:  now.Truncate(time.Minute),
+		},
+		{
+			name:  "few runs since from",
+			spec:  "0 * * * *",
+			from:  time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC),
+			count: 4,
+			first: time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC),
+			last:  time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "daily runs over a year",
+			spec:  "0 0 * * *",
+			from:  time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC),
+			count: maxCatchUpRuns,
+			first: time.Date(2024, 2, 23, 0, 0, 0, 0, time.UTC),
+			last:  time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "not due yet",
+			spec:  "0 0 * * *",
+			from:  time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC),
+			count: 0,
+		},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.spec)
+		if err != nil {
+			t.Fatalf("%s: Parse(%q): %v", tt.name, tt.spec, err)
+		}
+
+		runs := missedRuns(e, time.UTC, tt.from, now)
+		if len(runs) != tt.count {
+			t.Errorf("%s: got %d runs, want %d", tt.name, len(runs), tt.count)
+			continue
+		}
+		if tt.count == 0 {
+			continue
+		}
+		if !runs[0].Equal(tt.first) || !runs[len(runs)-1].Equal(tt.last) {
+			t.Errorf("%s: runs from %s to %s, want %s to %s", tt.name, runs[0], runs[len(runs)-1], tt.first, tt.last)
+		}
+	}
+}
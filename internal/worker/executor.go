@@ -1,240 +1,344 @@
-package worker
-
-import (
-	"database/sql"
-	"fmt"
-	"time"
-
-	"queuectl/internal/config"
-	"queuectl/internal/db"
-	"queuectl/internal/job"
-)
-
-// ExecuteJob executes a job with retry logic and state management
-func ExecuteJob(j *job.Job) error {
-	cfg, err := config.Load()
-	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
-	}
-
-	// Job is already in processing state (set by GetNextJob)
-	// No need to update it again
-
-	// Execute the job
-	result := job.Execute(j)
-
-	// Start transaction for result update
-	tx, err := db.GetDB().Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer tx.Rollback()
-
-	if result.Success {
-		// Job succeeded
-		if err := job.UpdateState(tx, j.ID, job.StateCompleted, j.Attempts, nil); err != nil {
-			return fmt.Errorf("failed to update job to completed: %w", err)
-		}
-	} else {
-		// Job failed - increment attempts
-		newAttempts := j.Attempts + 1
-
-		if newAttempts > j.MaxRetries {
-			// Move to DLQ
-			if err := job.UpdateState(tx, j.ID, job.StateDead, newAttempts, nil); err != nil {
-				return fmt.Errorf("failed to update job to dead: %w", err)
-			}
-		} else {
-			// Schedule retry with exponential backoff
-			nextRetryAt := job.CalculateNextRetry(newAttempts, cfg.BackoffBase)
-			// Set state to failed with next_retry_at - GetNextPendingJob will pick it up when ready
-			if err := job.UpdateState(tx, j.ID, job.StateFailed, newAttempts, &nextRetryAt); err != nil {
-				return fmt.Errorf("failed to update job for retry: %w", err)
-			}
-		}
-	}
-
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
-	return nil
-}
-
-// GetNextJob retrieves and locks the next available job
-func GetNextJob() (*job.Job, error) {
-	// For SQLite, we use an atomic UPDATE to claim the job
-	// This prevents race conditions by updating the state atomically
-	now := time.Now().Format(time.RFC3339)
-	
-	// Retry logic for SQLITE_BUSY errors
-	maxRetries := 5
-	retryDelay := 10 * time.Millisecond
-	
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		tx, err := db.GetDB().Begin()
-		if err != nil {
-			// Check if it's a SQLITE_BUSY error
-			errStr := err.Error()
-			if errStr == "database is locked" || 
-			   errStr == "database is locked (5)" ||
-			   errStr == "SQLITE_BUSY" ||
-			   errStr == "database is locked (5) (SQLITE_BUSY)" {
-				if attempt < maxRetries-1 {
-					time.Sleep(retryDelay)
-					retryDelay *= 2 // Exponential backoff
-					continue
-				}
-			}
-			return nil, fmt.Errorf("failed to begin transaction: %w", err)
-		}
-		
-		// Step 1: Get the ID of the next job to claim
-		var jobID string
-		selectQuery := `
-			SELECT id FROM jobs
-			WHERE (state = ? AND (next_retry_at IS NULL OR next_retry_at <= ?))
-			   OR (state = ? AND next_retry_at IS NOT NULL AND next_retry_at <= ?)
-			ORDER BY created_at ASC
-			LIMIT 1`
-		
-		err = tx.QueryRow(selectQuery, 
-			string(job.StatePending),
-			now,
-			string(job.StateFailed),
-			now,
-		).Scan(&jobID)
-		
-		if err == sql.ErrNoRows {
-			tx.Rollback()
-			return nil, nil // No jobs available
-		}
-		if err != nil {
-			tx.Rollback()
-			// Check if it's a SQLITE_BUSY error
-			errStr := err.Error()
-			if errStr == "database is locked" || 
-			   errStr == "database is locked (5)" ||
-			   errStr == "SQLITE_BUSY" ||
-			   errStr == "database is locked (5) (SQLITE_BUSY)" {
-				if attempt < maxRetries-1 {
-					time.Sleep(retryDelay)
-					retryDelay *= 2
-					continue
-				}
-			}
-			return nil, fmt.Errorf("failed to select next job: %w", err)
-		}
-		
-		// Step 2: Atomically update the job to processing state
-		// This prevents other workers from picking it up
-		updateQuery := `
-			UPDATE jobs
-			SET state = ?, updated_at = ?, next_retry_at = NULL
-			WHERE id = ? AND (state = ? OR state = ?)`
-		
-		result, err := tx.Exec(updateQuery,
-			string(job.StateProcessing),
-			time.Now().Format(time.RFC3339),
-			jobID,
-			string(job.StatePending),
-			string(job.StateFailed),
-		)
-		if err != nil {
-			tx.Rollback()
-			// Check if it's a SQLITE_BUSY error
-			errStr := err.Error()
-			if errStr == "database is locked" || 
-			   errStr == "database is locked (5)" ||
-			   errStr == "SQLITE_BUSY" ||
-			   errStr == "database is locked (5) (SQLITE_BUSY)" {
-				if attempt < maxRetries-1 {
-					time.Sleep(retryDelay)
-					retryDelay *= 2
-					continue
-				}
-			}
-			return nil, fmt.Errorf("failed to claim job: %w", err)
-		}
-		
-		rowsAffected, err := result.RowsAffected()
-		if err != nil {
-			tx.Rollback()
-			return nil, fmt.Errorf("failed to get rows affected: %w", err)
-		}
-		
-		if rowsAffected == 0 {
-			// Another worker claimed it between SELECT and UPDATE
-			tx.Rollback()
-			return nil, nil
-		}
-		
-		// Step 3: Select the job we just claimed
-		selectJobQuery := `
-			SELECT id, command, state, attempts, max_retries, created_at, updated_at, next_retry_at
-			FROM jobs
-			WHERE id = ?`
-		
-		var j job.Job
-		var createdAtStr, updatedAtStr string
-		var nextRetryAtStr sql.NullString
-		
-		err = tx.QueryRow(selectJobQuery, jobID).Scan(
-			&j.ID,
-			&j.Command,
-			&j.State,
-			&j.Attempts,
-			&j.MaxRetries,
-			&createdAtStr,
-			&updatedAtStr,
-			&nextRetryAtStr,
-		)
-		if err != nil {
-			tx.Rollback()
-			return nil, fmt.Errorf("failed to get claimed job: %w", err)
-		}
-		
-		// Parse timestamps
-		j.CreatedAt, err = time.Parse(time.RFC3339, createdAtStr)
-		if err != nil {
-			tx.Rollback()
-			return nil, fmt.Errorf("failed to parse created_at: %w", err)
-		}
-		
-		j.UpdatedAt, err = time.Parse(time.RFC3339, updatedAtStr)
-		if err != nil {
-			tx.Rollback()
-			return nil, fmt.Errorf("failed to parse updated_at: %w", err)
-		}
-		
-		if nextRetryAtStr.Valid {
-			nextRetryAt, err := time.Parse(time.RFC3339, nextRetryAtStr.String)
-			if err != nil {
-				tx.Rollback()
-				return nil, fmt.Errorf("failed to parse next_retry_at: %w", err)
-			}
-			j.NextRetryAt = &nextRetryAt
-		}
-		
-		if err := tx.Commit(); err != nil {
-			// Check if it's a SQLITE_BUSY error
-			errStr := err.Error()
-			if errStr == "database is locked" || 
-			   errStr == "database is locked (5)" ||
-			   errStr == "SQLITE_BUSY" ||
-			   errStr == "database is locked (5) (SQLITE_BUSY)" {
-				if attempt < maxRetries-1 {
-					time.Sleep(retryDelay)
-					retryDelay *= 2
-					continue
-				}
-			}
-			return nil, fmt.Errorf("failed to commit transaction: %w", err)
-		}
-		
-		return &j, nil
-	}
-	
-	return nil, fmt.Errorf("failed to get next job after %d retries: database is locked", maxRetries)
-}
-
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"queuectl/internal/config"
+	"queuectl/internal/db"
+	"queuectl/internal/job"
+)
+
+// ExecuteJob executes a job with retry logic and state management
+// Every attempt is recorded in the job's execution history under workerID.
+// Cancelling ctx kills the command and records the attempt as cancelled.
+// It returns the state the job was moved to.
+func ExecuteJob(ctx context.Context, j *job.Job, workerID string) (job.State, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return "", fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Job is already in processing state (set by GetNextJob)
+	// No need to update it again, but keep its lease alive while it runs
+	// and stop it if it gets cancelled
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	run, err := job.StartRun(j.ID, workerID)
+	if err != nil {
+		return "", fmt.Errorf("failed to record job run: %w", err)
+	}
+
+	stopHeartbeat := startHeartbeat(ctx, j.ID, workerID, cfg.LeaseDurationValue(), cancel)
+	defer stopHeartbeat()
+
+	// A per-job timeout overrides the configured default
+	timeout := time.Duration(j.Timeout)
+	if timeout == 0 {
+		timeout = cfg.JobTimeoutDuration()
+	}
+
+	// Execute the job
+	result := job.Execute(ctx, j, job.ExecOptions{Attempt: run.Attempt, Timeout: timeout})
+	stopHeartbeat()
+
+	// Start transaction for result update
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Keep the output of this attempt next to the job
+	if err := job.SaveLog(tx, j.ID, run.Attempt, result); err != nil {
+		return "", fmt.Errorf("failed to save job output: %w", err)
+	}
+
+	// Exit rules may turn the outcome around; the job's own rules come first
+	rules := append(append([]job.ExitRule{}, j.ExitRules...), cfg.ExitRules...)
+	action := job.Classify(rules, &result)
+
+	// A cancellation requested after the command finished still wins over a retry
+	cancelRequested, err := job.CancelRequestedTx(tx, j.ID)
+	if err != nil {
+		return "", err
+	}
+	cancelled := result.Cancelled || (cancelRequested && !result.Success)
+
+	runStatus := job.RunSucceeded
+	if cancelled {
+		runStatus = job.RunCancelled
+	} else if result.TimedOut {
+		runStatus = job.RunTimedOut
+	} else if !result.Success {
+		runStatus = job.RunFailed
+	}
+	if err := job.FinishRun(tx, run, runStatus, result); err != nil {
+		return "", fmt.Errorf("failed to record job run: %w", err)
+	}
+
+	// State updates only apply while we still hold the lease
+	var updateErr error
+	newState := job.StateFailed
+	if result.Success {
+		// Job succeeded
+		newState = job.StateCompleted
+		if err := job.UpdateLeasedState(tx, j.ID, workerID, job.StateCompleted, j.Attempts, nil); err != nil {
+			updateErr = fmt.Errorf("failed to update job to completed: %w", err)
+		}
+	} else {
+		// Job failed - increment attempts, unless an exit rule says it was a temporary failure
+		newAttempts := j.Attempts + 1
+		if action == job.ExitTempfail {
+			newAttempts = j.Attempts
+		}
+
+		if cancelled {
+			newState = job.StateCancelled
+			if err := job.UpdateLeasedState(tx, j.ID, workerID, job.StateCancelled, newAttempts, nil); err != nil {
+				updateErr = fmt.Errorf("failed to update job to cancelled: %w", err)
+			}
+		} else if action == job.ExitDead || newAttempts > j.MaxRetries {
+			// Move to DLQ
+			newState = job.StateDead
+			if err := job.UpdateLeasedState(tx, j.ID, workerID, job.StateDead, newAttempts, nil); err != nil {
+				updateErr = fmt.Errorf("failed to update job to dead: %w", err)
+			}
+		} else {
+			// Schedule retry according to the job's retry policy
+			nextRetryAt := cfg.RetryPolicy().Override(j.Retry).NextRetry(newAttempts)
+			// Set state to failed with next_retry_at - GetNextJob will pick it up when ready
+			if err := job.UpdateLeasedState(tx, j.ID, workerID, job.StateFailed, newAttempts, &nextRetryAt); err != nil {
+				updateErr = fmt.Errorf("failed to update job for retry: %w", err)
+			}
+		}
+	}
+
+	// If the lease was lost the job already belongs to someone else;
+	// keep the record of this attempt but leave the job alone
+	if updateErr != nil && !errors.Is(updateErr, job.ErrLeaseLost) {
+		return "", updateErr
+	}
+
+	if updateErr == nil && newState == job.StateDead {
+		reason := "job failed"
+		if result.Error != nil {
+			reason = result.Error.Error()
+		}
+		var exitCode *int
+		if result.ExitCode >= 0 {
+			exitCode = &result.ExitCode
+		}
+		if err := job.MarkDead(tx, j.ID, reason, exitCode, result.Stderr); err != nil {
+			return "", err
+		}
+	}
+
+	if updateErr == nil && newState == job.StateCompleted && len(result.Result) > 0 {
+		if err := job.SaveResult(tx, j.ID, result.Result); err != nil {
+			return "", err
+		}
+	}
+
+	// Unblock or fail the jobs waiting on this one
+	if updateErr == nil {
+		if err := job.ResolveDependents(tx, j.ID, newState); err != nil {
+			return "", fmt.Errorf("failed to resolve dependent jobs: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if updateErr != nil {
+		return "", updateErr
+	}
+	return newState, nil
+}
+
+// ClaimOptions controls how GetNextJob claims a job
+type ClaimOptions struct {
+	// WorkerID becomes the lease owner of the claimed job
+	WorkerID string
+	// Lease is how long the claim is valid without a heartbeat
+	Lease time.Duration
+	// PriorityAging raises a waiting job's priority by one for every interval
+	// it has been queued, so low priority jobs cannot starve. 0 disables aging.
+	PriorityAging time.Duration
+	// Queues limits the claim to these queues. Empty means any queue.
+	Queues []string
+}
+
+// GetNextJob retrieves and leases the next available job
+// The lease must be renewed with job.RenewLease while the job runs, otherwise
+// ReapExpiredLeases hands the job back to the queue
+func GetNextJob(opts ClaimOptions) (*job.Job, error) {
+	// For SQLite, we use an atomic UPDATE to claim the job
+	// This prevents race conditions by updating the state atomically
+	now := time.Now().Format(time.RFC3339)
+
+	orderBy := "priority DESC, created_at ASC"
+	var orderArgs []interface{}
+	if agingSeconds := int64(opts.PriorityAging / time.Second); agingSeconds > 0 {
+		// Every agingSeconds spent waiting (since run_at for scheduled jobs) adds one to the job's priority
+		orderBy = "priority + (strftime('%s', ?) - strftime('%s', COALESCE(run_at, created_at))) / ? DESC, created_at ASC"
+		orderArgs = []interface{}{now, agingSeconds}
+	}
+
+	queueFilter := ""
+	var queueArgs []interface{}
+	if len(opts.Queues) > 0 {
+		queueFilter = " AND queue IN (?" + strings.Repeat(", ?", len(opts.Queues)-1) + ")"
+		for _, q := range opts.Queues {
+			queueArgs = append(queueArgs, q)
+		}
+	}
+
+	// Retry logic for SQLITE_BUSY errors
+	maxRetries := 5
+	retryDelay := 10 * time.Millisecond
+
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		tx, err := db.GetDB().Begin()
+		if err != nil {
+			// Check if it's a SQLITE_BUSY error
+			errStr := err.Error()
+			if errStr == "database is locked" ||
+				errStr == "database is locked (5)" ||
+				errStr == "SQLITE_BUSY" ||
+				errStr == "database is locked (5) (SQLITE_BUSY)" {
+				if attempt < maxRetries-1 {
+					time.Sleep(retryDelay)
+					retryDelay *= 2 // Exponential backoff
+					continue
+				}
+			}
+			return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		}
+
+		// Step 1: Get the ID of the next job to claim
+		// Highest (effective) priority first, oldest first within a priority
+		var jobID string
+		selectQuery := `
+			SELECT id FROM jobs
+			WHERE ((state = ? AND (next_retry_at IS NULL OR next_retry_at <= ?) AND (run_at IS NULL OR run_at <= ?))
+			   OR (state = ? AND next_retry_at IS NOT NULL AND next_retry_at <= ?))` + queueFilter + `
+			ORDER BY ` + orderBy + `
+			LIMIT 1`
+
+		args := []interface{}{
+			string(job.StatePending),
+			now,
+			now,
+			string(job.StateFailed),
+			now,
+		}
+		args = append(args, queueArgs...)
+		args = append(args, orderArgs...)
+		err = tx.QueryRow(selectQuery, args...).Scan(&jobID)
+
+		if err == sql.ErrNoRows {
+			tx.Rollback()
+			return nil, nil // No jobs available
+		}
+		if err != nil {
+			tx.Rollback()
+			// Check if it's a SQLITE_BUSY error
+			errStr := err.Error()
+			if errStr == "database is locked" ||
+				errStr == "database is locked (5)" ||
+				errStr == "SQLITE_BUSY" ||
+				errStr == "database is locked (5) (SQLITE_BUSY)" {
+				if attempt < maxRetries-1 {
+					time.Sleep(retryDelay)
+					retryDelay *= 2
+					continue
+				}
+			}
+			return nil, fmt.Errorf("failed to select next job: %w", err)
+		}
+
+		// Step 2: Atomically update the job to processing state and take the lease
+		// This prevents other workers from picking it up
+		updateQuery := `
+			UPDATE jobs
+			SET state = ?, updated_at = ?, next_retry_at = NULL, lease_owner = ?, lease_expires_at = ?
+			WHERE id = ? AND (state = ? OR state = ?)`
+
+		claimedAt := time.Now()
+		result, err := tx.Exec(updateQuery,
+			string(job.StateProcessing),
+			claimedAt.Format(time.RFC3339),
+			opts.WorkerID,
+			claimedAt.Add(opts.Lease).Format(time.RFC3339),
+			jobID,
+			string(job.StatePending),
+			string(job.StateFailed),
+		)
+		if err != nil {
+			tx.Rollback()
+			// Check if it's a SQLITE_BUSY error
+			errStr := err.Error()
+			if errStr == "database is locked" ||
+				errStr == "database is locked (5)" ||
+				errStr == "SQLITE_BUSY" ||
+				errStr == "database is locked (5) (SQLITE_BUSY)" {
+				if attempt < maxRetries-1 {
+					time.Sleep(retryDelay)
+					retryDelay *= 2
+					continue
+				}
+			}
+			return nil, fmt.Errorf("failed to claim job: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+
+		if rowsAffected == 0 {
+			// Another worker claimed it between SELECT and UPDATE. Other jobs may
+			// still be waiting, so look again right away instead of reporting an
+			// empty queue; this is not a lock error and does not use up a retry.
+			tx.Rollback()
+			attempt--
+			continue
+		}
+
+		// Step 3: Select the job we just claimed
+		j, err := job.GetByIDTx(tx, jobID)
+		if err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to get claimed job: %w", err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			// Check if it's a SQLITE_BUSY error
+			errStr := err.Error()
+			if errStr == "database is locked" ||
+				errStr == "database is locked (5)" ||
+				errStr == "SQLITE_BUSY" ||
+				errStr == "database is locked (5) (SQLITE_BUSY)" {
+				if attempt < maxRetries-1 {
+					time.Sleep(retryDelay)
+					retryDelay *= 2
+					continue
+				}
+			}
+			return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+
+		return j, nil
+	}
+
+	return nil, fmt.Errorf("failed to get next job after %d retries: database is locked", maxRetries)
+}
+
@@ -0,0 +1,67 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"queuectl/internal/job"
+)
+
+// cancelPollInterval is how often a running job is checked for a cancellation request
+const cancelPollInterval = time.Second
+
+// startHeartbeat renews the lease on a running job every third of the lease duration
+// and calls cancel once someone asks for the job to be cancelled.
+// It stops when ctx is done or the returned function is called; the function may be called more than once
+func startHeartbeat(ctx context.Context, jobID, workerID string, lease time.Duration, cancel func()) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	var once sync.Once
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(lease / 3)
+		defer ticker.Stop()
+
+		cancelTicker := time.NewTicker(cancelPollInterval)
+		defer cancelTicker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-cancelTicker.C:
+				requested, err := job.CancelRequested(jobID)
+				if err != nil {
+					fmt.Printf("Worker %s: Failed to check job %s for cancellation: %v\n", workerID, jobID, err)
+					continue
+				}
+				if requested {
+					fmt.Printf("Worker %s: Cancelling job %s\n", workerID, jobID)
+					cancel()
+					cancelTicker.Stop()
+				}
+			case <-ticker.C:
+				if err := job.RenewLease(jobID, workerID, lease); err != nil {
+					if errors.Is(err, job.ErrLeaseLost) {
+						fmt.Printf("Worker %s: Lost lease on job %s, it may be retried elsewhere\n", workerID, jobID)
+						return
+					}
+					fmt.Printf("Worker %s: Failed to renew lease on job %s: %v\n", workerID, jobID, err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() { close(done) })
+		<-stopped
+	}
+}
+
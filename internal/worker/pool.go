@@ -1,169 +1,535 @@
-package worker
-
-import (
-	"context"
-	"fmt"
-	"sync"
-	"time"
-
-	"queuectl/internal/job"
-)
-
-// Pool manages a pool of workers
-type Pool struct {
-	workerCount int
-	workers     []*Worker
-	wg          sync.WaitGroup
-	ctx         context.Context
-	cancel      context.CancelFunc
-	mu          sync.Mutex
-}
-
-// Worker represents a single worker goroutine
-type Worker struct {
-	id          int
-	pool        *Pool
-	running     bool
-	currentJob  *job.Job
-	mu          sync.Mutex
-}
-
-var globalPool *Pool
-
-// StartPool starts a worker pool with the specified number of workers
-func StartPool(count int) error {
-	if globalPool != nil && globalPool.IsRunning() {
-		return fmt.Errorf("worker pool is already running")
-	}
-
-	ctx, cancel := context.WithCancel(context.Background())
-	pool := &Pool{
-		workerCount: count,
-		workers:     make([]*Worker, count),
-		ctx:         ctx,
-		cancel:      cancel,
-	}
-
-	for i := 0; i < count; i++ {
-		worker := &Worker{
-			id:   i + 1,
-			pool: pool,
-		}
-		pool.workers[i] = worker
-		pool.wg.Add(1)
-		go worker.run()
-	}
-
-	globalPool = pool
-	return nil
-}
-
-// StopPool stops all workers gracefully
-func StopPool() error {
-	if globalPool == nil || !globalPool.IsRunning() {
-		return fmt.Errorf("worker pool is not running")
-	}
-
-	globalPool.cancel()
-	globalPool.wg.Wait()
-	globalPool = nil
-	return nil
-}
-
-// GetPool returns the global worker pool
-func GetPool() *Pool {
-	return globalPool
-}
-
-// IsRunning returns whether the pool is currently running
-func (p *Pool) IsRunning() bool {
-	if p == nil {
-		return false
-	}
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	return p.ctx.Err() == nil
-}
-
-// GetWorkerCount returns the number of active workers
-func (p *Pool) GetWorkerCount() int {
-	if p == nil {
-		return 0
-	}
-	return p.workerCount
-}
-
-// run is the main worker loop
-func (w *Worker) run() {
-	defer w.pool.wg.Done()
-
-	w.pool.mu.Lock()
-	w.running = true
-	w.pool.mu.Unlock()
-
-	for {
-		// Check for shutdown signal
-		select {
-		case <-w.pool.ctx.Done():
-			// Graceful shutdown - finish current job if any
-			w.mu.Lock()
-			currentJob := w.currentJob
-			w.mu.Unlock()
-			
-			if currentJob != nil {
-				// Job is currently executing (ExecuteJob is blocking)
-				// It will finish before we can exit, which is correct behavior
-				fmt.Printf("Worker %d: Finishing current job %s before shutdown...\n", w.id, currentJob.ID)
-			}
-			
-			w.pool.mu.Lock()
-			w.running = false
-			w.pool.mu.Unlock()
-			return
-		default:
-		}
-
-		// Try to get next job
-		j, err := GetNextJob()
-		if err != nil {
-			fmt.Printf("Worker %d: Error getting next job: %v\n", w.id, err)
-			time.Sleep(1 * time.Second)
-			continue
-		}
-
-		if j == nil {
-			// No jobs available, wait a bit
-			time.Sleep(1 * time.Second)
-			continue
-		}
-
-		// Track current job
-		w.mu.Lock()
-		w.currentJob = j
-		w.mu.Unlock()
-
-		// Execute the job (blocking call - if shutdown is requested during execution,
-		// this will complete first, then we'll check ctx.Done() on next iteration)
-		if err := ExecuteJob(j); err != nil {
-			fmt.Printf("Worker %d: Error executing job %s: %v\n", w.id, j.ID, err)
-		} else {
-			fmt.Printf("Worker %d: Completed job %s\n", w.id, j.ID)
-		}
-
-		// Clear current job
-		w.mu.Lock()
-		w.currentJob = nil
-		w.mu.Unlock()
-
-		// Check for shutdown after job execution (don't pick up new job if shutting down)
-		select {
-		case <-w.pool.ctx.Done():
-			w.pool.mu.Lock()
-			w.running = false
-			w.pool.mu.Unlock()
-			return
-		default:
-		}
-	}
-}
-
+package worker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"queuectl/internal/config"
+	"queuectl/internal/job"
+	"queuectl/internal/schedule"
+)
+
+// Pool manages a pool of workers
+type Pool struct {
+	workerCount int
+	lease       time.Duration
+	aging       time.Duration
+	queues      []QueueSubscription
+	instance    string
+	nextID      int
+	paused      bool
+	maxPoll     time.Duration
+	wake        chan struct{} // closed and replaced by Wake
+	workers     []*Worker     // includes workers that are stopping after a scale down
+	wg          sync.WaitGroup
+	ctx         context.Context
+	cancel      context.CancelFunc
+	mu          sync.Mutex
+}
+
+// Worker represents a single worker goroutine
+type Worker struct {
+	id         int
+	name       string
+	pool       *Pool
+	running    bool
+	stopping   bool
+	currentJob *job.Job
+	cancelJob  context.CancelFunc // kills the current job's command
+	ctx        context.Context
+	stop       context.CancelFunc
+	mu         sync.Mutex
+}
+
+// PoolStatus describes a running pool
+type PoolStatus struct {
+	PID     int      `json:"pid"`
+	Workers int      `json:"workers"`
+	Busy    int      `json:"busy"`
+	Paused  bool     `json:"paused"`
+	Queues  string   `json:"queues"`
+	Jobs    []string `json:"jobs"` // jobs currently running
+}
+
+var globalPool *Pool
+
+// schedulerInterval is how often a pool checks for due schedules
+const schedulerInterval = time.Second
+
+// minPollInterval is how soon an idle worker looks for a job again after finding none;
+// the wait doubles on every empty check up to the configured poll-interval
+const minPollInterval = 10 * time.Millisecond
+
+// StartPool starts a worker pool with the specified number of workers
+// The workers only claim jobs from the given queues, or from every queue if none are given
+func StartPool(count int, queues []QueueSubscription) error {
+	if globalPool != nil && globalPool.IsRunning() {
+		return fmt.Errorf("worker pool is already running")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := &Pool{
+		lease:    cfg.LeaseDurationValue(),
+		aging:    cfg.PriorityAgingValue(),
+		queues:   queues,
+		instance: instanceID(),
+		maxPoll:  cfg.PollIntervalValue(),
+		wake:     make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	pool.mu.Lock()
+	for i := 0; i < count; i++ {
+		pool.addWorker()
+	}
+	pool.mu.Unlock()
+
+	// Recover jobs left behind by workers that died without finishing them
+	pool.wg.Add(1)
+	go pool.reap()
+
+	// Keep the workers' registry entries fresh so other processes see them as live
+	pool.wg.Add(1)
+	go pool.heartbeat()
+
+	// Enqueue jobs for recurring schedules
+	pool.wg.Add(1)
+	go pool.runSchedules()
+
+	globalPool = pool
+	return nil
+}
+
+// instanceID identifies this worker process across hosts as host:pid
+func instanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
+
+// StopPool stops all workers gracefully
+func StopPool() error {
+	if globalPool == nil || !globalPool.IsRunning() {
+		return fmt.Errorf("worker pool is not running")
+	}
+
+	globalPool.cancel()
+	globalPool.wg.Wait()
+	globalPool = nil
+	return nil
+}
+
+// GetPool returns the global worker pool
+func GetPool() *Pool {
+	return globalPool
+}
+
+// IsRunning returns whether the pool is currently running
+func (p *Pool) IsRunning() bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.ctx.Err() == nil
+}
+
+// Queues returns a description of the queues the pool claims from
+func (p *Pool) Queues() string {
+	if p == nil {
+		return ""
+	}
+	return queueNames(p.queues)
+}
+
+// GetWorkerCount returns the number of active workers
+func (p *Pool) GetWorkerCount() int {
+	if p == nil {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.workerCount
+}
+
+// addWorker starts one more worker; p.mu must be held
+func (p *Pool) addWorker() {
+	p.nextID++
+	ctx, stop := context.WithCancel(p.ctx)
+	w := &Worker{
+		id:   p.nextID,
+		name: fmt.Sprintf("%s:%d", p.instance, p.nextID),
+		pool: p,
+		ctx:  ctx,
+		stop: stop,
+	}
+	p.workers = append(p.workers, w)
+	p.workerCount++
+	p.wg.Add(1)
+	go w.run()
+}
+
+// removeWorker forgets a worker that has exited
+func (p *Pool) removeWorker(w *Worker) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, other := range p.workers {
+		if other == w {
+			p.workers = append(p.workers[:i], p.workers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Scale changes the number of workers to count
+// Surplus workers finish their current job before they exit.
+func (p *Pool) Scale(count int) error {
+	if count < 1 {
+		return fmt.Errorf("worker count must be at least 1")
+	}
+	if !p.IsRunning() {
+		return fmt.Errorf("worker pool is not running")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for p.workerCount < count {
+		p.addWorker()
+	}
+	// Stop the newest workers first
+	for i := len(p.workers) - 1; i >= 0 && p.workerCount > count; i-- {
+		w := p.workers[i]
+		if !w.stopping {
+			w.stopping = true
+			w.stop()
+			p.workerCount--
+		}
+	}
+	return nil
+}
+
+// Pause stops the pool from claiming new jobs; running jobs carry on
+func (p *Pool) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paused = true
+}
+
+// Resume lets a paused pool claim jobs again
+func (p *Pool) Resume() {
+	p.mu.Lock()
+	p.paused = false
+	p.mu.Unlock()
+	p.Wake()
+}
+
+// Wake makes every idle worker look for a job right away, e.g. after new jobs were enqueued
+func (p *Pool) Wake() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	close(p.wake)
+	p.wake = make(chan struct{})
+}
+
+// wakeup returns the channel the next call to Wake closes
+func (p *Pool) wakeup() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.wake
+}
+
+// wait blocks for up to d, returning early when the worker is stopped or woken
+// It reports whether the worker was woken.
+func (w *Worker) wait(wake <-chan struct{}, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-wake:
+		return true
+	case <-w.ctx.Done():
+	case <-timer.C:
+	}
+	return false
+}
+
+// isPaused reports whether claiming is paused
+func (p *Pool) isPaused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused
+}
+
+// Drain pauses the pool and waits until none of its workers is running a job
+func (p *Pool) Drain(ctx context.Context) error {
+	p.Pause()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if len(p.Status().Jobs) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("jobs still running: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// CancelJob kills the command of a job running in this pool
+// The worker then records the attempt as cancelled.
+func (p *Pool) CancelJob(id string) error {
+	p.mu.Lock()
+	workers := append([]*Worker(nil), p.workers...)
+	p.mu.Unlock()
+
+	for _, w := range workers {
+		w.mu.Lock()
+		running := w.currentJob != nil && w.currentJob.ID == id
+		cancelJob := w.cancelJob
+		w.mu.Unlock()
+		if running {
+			cancelJob()
+			return nil
+		}
+	}
+	return fmt.Errorf("job %s is not running in this worker pool", id)
+}
+
+// Status reports the pool's workers, the jobs they are running and whether claiming is paused
+func (p *Pool) Status() PoolStatus {
+	p.mu.Lock()
+	status := PoolStatus{
+		PID:     os.Getpid(),
+		Workers: p.workerCount,
+		Paused:  p.paused,
+		Queues:  queueNames(p.queues),
+		Jobs:    []string{},
+	}
+	workers := append([]*Worker(nil), p.workers...)
+	p.mu.Unlock()
+
+	for _, w := range workers {
+		w.mu.Lock()
+		if w.currentJob != nil {
+			status.Busy++
+			status.Jobs = append(status.Jobs, w.currentJob.ID)
+		}
+		w.mu.Unlock()
+	}
+	return status
+}
+
+// outcome describes the state an attempt left a job in, for the worker log
+func outcome(state job.State) string {
+	switch state {
+	case job.StateFailed:
+		return "failed, retry scheduled"
+	case job.StateDead:
+		return "failed, moved to the DLQ"
+	default:
+		return string(state)
+	}
+}
+
+// run is the main worker loop
+func (w *Worker) run() {
+	defer w.pool.wg.Done()
+	defer w.pool.removeWorker(w)
+
+	w.pool.mu.Lock()
+	w.running = true
+	w.pool.mu.Unlock()
+
+	if err := w.register(); err != nil {
+		fmt.Printf("Worker %d: %v\n", w.id, err)
+	}
+	defer func() {
+		if err := w.unregister(); err != nil {
+			fmt.Printf("Worker %d: %v\n", w.id, err)
+		}
+	}()
+
+	idle := minPollInterval
+	for {
+		// Check for shutdown signal
+		select {
+		case <-w.ctx.Done():
+			// Graceful shutdown - finish current job if any
+			w.mu.Lock()
+			currentJob := w.currentJob
+			w.mu.Unlock()
+
+			if currentJob != nil {
+				// Job is currently executing (ExecuteJob is blocking)
+				// It will finish before we can exit, which is correct behavior
+				fmt.Printf("Worker %d: Finishing current job %s before shutdown...\n", w.id, currentJob.ID)
+			}
+
+			w.pool.mu.Lock()
+			w.running = false
+			w.pool.mu.Unlock()
+			return
+		default:
+		}
+
+		// Taken before claiming so that a Wake for a job enqueued after the claim is not missed
+		wake := w.pool.wakeup()
+
+		if w.pool.isPaused() {
+			// Resume wakes the worker
+			w.wait(wake, w.pool.maxPoll)
+			continue
+		}
+
+		// Try to get next job
+		j, err := w.claim()
+		if err != nil {
+			fmt.Printf("Worker %d: Error getting next job: %v\n", w.id, err)
+			w.wait(nil, time.Second)
+			continue
+		}
+
+		if j == nil {
+			// No jobs available: wait for a wakeup, checking again less often the longer we stay idle
+			if w.wait(wake, idle) {
+				idle = minPollInterval
+			} else {
+				idle = min(idle*2, w.pool.maxPoll)
+			}
+			continue
+		}
+		idle = minPollInterval
+
+		// Track current job
+		jobCtx, cancelJob := context.WithCancel(context.Background())
+		w.mu.Lock()
+		w.currentJob = j
+		w.cancelJob = cancelJob
+		w.mu.Unlock()
+		if err := w.setCurrentJob(j.ID); err != nil {
+			fmt.Printf("Worker %d: %v\n", w.id, err)
+		}
+
+		// Execute the job (blocking call - if shutdown is requested during execution,
+		// this will complete first, then we'll check ctx.Done() on next iteration)
+		if state, err := ExecuteJob(jobCtx, j, w.name); err != nil {
+			fmt.Printf("Worker %d: Error executing job %s: %v\n", w.id, j.ID, err)
+		} else {
+			fmt.Printf("Worker %d: Job %s %s\n", w.id, j.ID, outcome(state))
+		}
+
+		// Clear current job
+		cancelJob()
+		w.mu.Lock()
+		w.currentJob = nil
+		w.cancelJob = nil
+		w.mu.Unlock()
+		if err := w.setCurrentJob(""); err != nil {
+			fmt.Printf("Worker %d: %v\n", w.id, err)
+		}
+
+		// Check for shutdown after job execution (don't pick up new job if shutting down)
+		select {
+		case <-w.ctx.Done():
+			w.pool.mu.Lock()
+			w.running = false
+			w.pool.mu.Unlock()
+			return
+		default:
+		}
+	}
+}
+
+// reap periodically returns jobs with expired leases to the queue
+func (p *Pool) reap() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.lease)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Printf("Reaper: Failed to load config: %v\n", err)
+			continue
+		}
+
+		recovered, err := job.ReapExpiredLeases(cfg.RetryPolicy())
+		if err != nil {
+			fmt.Printf("Reaper: Error recovering expired leases: %v\n", err)
+			continue
+		}
+		for _, r := range recovered {
+			fmt.Printf("Reaper: Recovered job %s from %s (now %s)\n", r.ID, r.Owner, r.State)
+		}
+		if len(recovered) > 0 {
+			p.Wake()
+		}
+	}
+}
+
+// claim claims the next job from the pool's queues, trying them in weighted order
+func (w *Worker) claim() (*job.Job, error) {
+	for _, queues := range claimOrder(w.pool.queues) {
+		j, err := GetNextJob(ClaimOptions{
+			WorkerID:      w.name,
+			Lease:         w.pool.lease,
+			PriorityAging: w.pool.aging,
+			Queues:        queues,
+		})
+		if err != nil || j != nil {
+			return j, err
+		}
+	}
+	return nil, nil
+}
+
+// runSchedules enqueues jobs for due schedules
+// Every worker process runs this loop; schedule.RunDue makes sure each run is enqueued once
+func (p *Pool) runSchedules() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		created, err := schedule.RunDue(time.Now())
+		for _, id := range created {
+			fmt.Printf("Scheduler: Enqueued job %s\n", id)
+		}
+		if len(created) > 0 {
+			p.Wake()
+		}
+		if err != nil {
+			fmt.Printf("Scheduler: Error enqueueing scheduled jobs: %v\n", err)
+		}
+	}
+}
+
@@ -0,0 +1,112 @@
+package worker
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// QueueSubscription is a queue a pool claims jobs from, with its relative weight
+type QueueSubscription struct {
+	Name   string
+	Weight int
+}
+
+// ParseQueueSubscriptions parses a --queues value such as "reports,emails" or "reports:3,emails:1"
+// Queues without an explicit weight get weight 1. An empty value subscribes to every queue.
+func ParseQueueSubscriptions(value string) ([]QueueSubscription, error) {
+	var subs []QueueSubscription
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		sub := QueueSubscription{Name: part, Weight: 1}
+		if name, weight, ok := strings.Cut(part, ":"); ok {
+			w, err := strconv.Atoi(weight)
+			if err != nil || w < 1 {
+				return nil, fmt.Errorf("invalid weight '%s' for queue '%s' (must be a positive number)", weight, name)
+			}
+			sub = QueueSubscription{Name: name, Weight: w}
+		}
+
+		if sub.Name == "" {
+			return nil, fmt.Errorf("empty queue name in '%s'", value)
+		}
+		if seen[sub.Name] {
+			return nil, fmt.Errorf("queue '%s' listed more than once", sub.Name)
+		}
+		seen[sub.Name] = true
+		subs = append(subs, sub)
+	}
+
+	return subs, nil
+}
+
+// weighted reports whether the subscriptions use different weights
+func weighted(subs []QueueSubscription) bool {
+	for _, sub := range subs {
+		if sub.Weight != subs[0].Weight {
+			return true
+		}
+	}
+	return false
+}
+
+// claimOrder returns the order in which queues should be tried for the next claim
+// Unweighted subscriptions are claimed from together in a single group so that
+// priorities apply across them. Weighted subscriptions are tried one queue at a
+// time, each queue coming first with probability proportional to its weight.
+func claimOrder(subs []QueueSubscription) [][]string {
+	if len(subs) == 0 {
+		return [][]string{nil} // any queue
+	}
+
+	if !weighted(subs) {
+		names := make([]string, len(subs))
+		for i, sub := range subs {
+			names[i] = sub.Name
+		}
+		return [][]string{names}
+	}
+
+	remaining := append([]QueueSubscription(nil), subs...)
+	order := make([][]string, 0, len(subs))
+	for len(remaining) > 0 {
+		total := 0
+		for _, sub := range remaining {
+			total += sub.Weight
+		}
+
+		pick := rand.Intn(total)
+		for i, sub := range remaining {
+			if pick < sub.Weight {
+				order = append(order, []string{sub.Name})
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+			pick -= sub.Weight
+		}
+	}
+	return order
+}
+
+// queueNames returns the subscribed queue names, for display
+func queueNames(subs []QueueSubscription) string {
+	if len(subs) == 0 {
+		return "all queues"
+	}
+	parts := make([]string, len(subs))
+	for i, sub := range subs {
+		parts[i] = sub.Name
+		if weighted(subs) {
+			parts[i] += ":" + strconv.Itoa(sub.Weight)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
@@ -0,0 +1,145 @@
+package worker
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"queuectl/internal/db"
+)
+
+// registryInterval is how often a pool refreshes its workers' heartbeats in the workers table
+const registryInterval = 5 * time.Second
+
+// staleAfter is how long a registered worker can go without a heartbeat before it counts as stale
+const staleAfter = 3 * registryInterval
+
+// Info describes a worker registered in the workers table, possibly in another process
+type Info struct {
+	ID            string    `json:"id"`
+	Host          string    `json:"host"`
+	PID           int       `json:"pid"`
+	Queues        string    `json:"queues"`
+	CurrentJob    string    `json:"current_job,omitempty"`
+	StartedAt     time.Time `json:"started_at"`
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+	Stale         bool      `json:"stale"`
+}
+
+// register adds the worker to the workers table
+func (w *Worker) register() error {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	now := time.Now().Format(time.RFC3339)
+
+	_, err = db.GetDB().Exec(
+		`INSERT OR REPLACE INTO workers (id, host, pid, queues, current_job, started_at, last_heartbeat) VALUES (?, ?, ?, ?, NULL, ?, ?)`,
+		w.name, host, os.Getpid(), w.pool.Queues(), now, now,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register worker: %w", err)
+	}
+	return nil
+}
+
+// unregister removes the worker from the workers table
+func (w *Worker) unregister() error {
+	if _, err := db.GetDB().Exec(`DELETE FROM workers WHERE id = ?`, w.name); err != nil {
+		return fmt.Errorf("failed to unregister worker: %w", err)
+	}
+	return nil
+}
+
+// setCurrentJob records the job the worker is running, or none if id is empty
+func (w *Worker) setCurrentJob(id string) error {
+	var currentJob interface{}
+	if id != "" {
+		currentJob = id
+	}
+	if _, err := db.GetDB().Exec(`UPDATE workers SET current_job = ? WHERE id = ?`, currentJob, w.name); err != nil {
+		return fmt.Errorf("failed to update worker: %w", err)
+	}
+	return nil
+}
+
+// heartbeat periodically refreshes last_heartbeat for every worker in the pool
+func (p *Pool) heartbeat() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(registryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		p.mu.Lock()
+		args := []interface{}{time.Now().Format(time.RFC3339)}
+		for _, w := range p.workers {
+			args = append(args, w.name)
+		}
+		p.mu.Unlock()
+		if len(args) == 1 {
+			continue
+		}
+
+		query := `UPDATE workers SET last_heartbeat = ? WHERE id IN (?` + strings.Repeat(", ?", len(args)-2) + `)`
+
+		if _, err := db.GetDB().Exec(query, args...); err != nil {
+			fmt.Printf("Registry: Failed to update worker heartbeats: %v\n", err)
+		}
+	}
+}
+
+// ListWorkers returns every registered worker, marking those whose heartbeat is overdue as stale
+func ListWorkers() ([]*Info, error) {
+	rows, err := db.GetDB().Query(`
+		SELECT id, host, pid, queues, COALESCE(current_job, ''), started_at, last_heartbeat
+		FROM workers
+		ORDER BY started_at ASC, id ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workers: %w", err)
+	}
+	defer rows.Close()
+
+	cutoff := time.Now().Add(-staleAfter)
+	workers := []*Info{}
+	for rows.Next() {
+		var info Info
+		var startedAtStr, lastHeartbeatStr string
+		if err := rows.Scan(&info.ID, &info.Host, &info.PID, &info.Queues, &info.CurrentJob, &startedAtStr, &lastHeartbeatStr); err != nil {
+			return nil, fmt.Errorf("failed to scan worker: %w", err)
+		}
+		if info.StartedAt, err = time.Parse(time.RFC3339, startedAtStr); err != nil {
+			return nil, fmt.Errorf("failed to parse started_at: %w", err)
+		}
+		if info.LastHeartbeat, err = time.Parse(time.RFC3339, lastHeartbeatStr); err != nil {
+			return nil, fmt.Errorf("failed to parse last_heartbeat: %w", err)
+		}
+		info.Stale = info.LastHeartbeat.Before(cutoff)
+		workers = append(workers, &info)
+	}
+
+	return workers, rows.Err()
+}
+
+// PruneStaleWorkers removes workers whose heartbeat is overdue, such as those of a killed process
+func PruneStaleWorkers() (int, error) {
+	cutoff := time.Now().Add(-staleAfter).Format(time.RFC3339)
+	result, err := db.GetDB().Exec(`DELETE FROM workers WHERE last_heartbeat < ?`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune workers: %w", err)
+	}
+	pruned, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return int(pruned), nil
+}
+